@@ -0,0 +1,119 @@
+package main
+
+// This file contains the output of arcs in Gcode, either with the center (I
+// and J words) or with the radius (R word). The radius form is ambiguous for
+// half circles, and can not describe full circles, so long arcs are split.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/joushou/gocnc/gcode"
+)
+
+// Forms of arcs in Gcode
+const (
+	CenterForm = "ij"
+	RadiusForm = "r"
+)
+
+// ArcFormat describes how arcs are written in Gcode
+type ArcFormat struct {
+	Form      string  // CenterForm or RadiusForm, CenterForm if empty
+	MaxSweep  float64 // longest arc in the radius form, in radians, defaultSweep if 0 or beyond maxSweep
+	Tolerance float64 // maximum difference between the start and end radii
+}
+
+// maxSweep is the longest arc of the radius form, whose arcs get ambiguous
+// near half circles
+const maxSweep = math.Pi * 0.9
+
+// defaultSweep is the longest arc of the radius form when MaxSweep is not
+// usable, well away from maxSweep
+const defaultSweep = 3 * math.Pi / 4
+
+// parts returns the number of arcs a needs to be split in
+func (f ArcFormat) parts(a *Arc) int {
+	sweep := math.Abs(a.Sweep())
+	if f.Form != RadiusForm {
+		if a.From.near(a.To) {
+			// full circles are split in halves
+			return 2
+		}
+		return 1
+	}
+	max := f.MaxSweep
+	if max <= 0 || max > maxSweep {
+		max = defaultSweep
+	}
+	return int(math.Ceil(sweep / max))
+}
+
+// Split returns the arcs a is written as
+func (f ArcFormat) Split(a *Arc) []*Arc {
+	n := f.parts(a)
+	if n <= 1 {
+		return []*Arc{a}
+	}
+	sweep := math.Abs(a.Sweep())
+	arcs := make([]*Arc, 0, n)
+	from := a.From
+	for i := 1; i <= n; i++ {
+		to := a.To
+		if i < n {
+			to = a.at(sweep * float64(i) / float64(n))
+		}
+		arcs = append(arcs, &Arc{from, to, a.Center, a.CW})
+		from = to
+	}
+	return arcs
+}
+
+// Gcode returns the blocks of an arc
+func (f ArcFormat) Gcode(a *Arc) []gcode.Block {
+	if f.Form != RadiusForm {
+		bs := []gcode.Block{}
+		for _, s := range f.Split(a) {
+			bs = append(bs, s.Gcode())
+		}
+		return bs
+	}
+
+	bs := []gcode.Block{}
+	for _, s := range f.Split(a) {
+		b := gcode.Block{}
+		if s.CW {
+			b.AppendNode(word('G', 2))
+		} else {
+			b.AppendNode(word('G', 3))
+		}
+		b.AppendNodes(xyz(s.To)...)
+		r := (s.From.Diff(s.Center).Norm() + s.To.Diff(s.Center).Norm()) / 2
+		b.AppendNode(word('R', r))
+		bs = append(bs, b)
+	}
+	return bs
+}
+
+// Check returns the arcs of the toolpath whose start and end are not at the
+// same distance of the center, within tolerance, and a maximum sweep too long
+// for the radius form.
+func (f ArcFormat) Check(tp Toolpath) []error {
+	errs := []error{}
+	if f.Form == RadiusForm && f.MaxSweep > maxSweep {
+		errs = append(errs, fmt.Errorf("maximum sweep %g beyond %g, arcs are split at %g", f.MaxSweep, maxSweep, defaultSweep))
+	}
+	for i, p := range tp.Passes {
+		for _, m := range p.Path {
+			a, ok := m.(*Arc)
+			if !ok {
+				continue
+			}
+			r1, r2 := a.From.Diff(a.Center).Norm(), a.To.Diff(a.Center).Norm()
+			if math.Abs(r1-r2) > f.Tolerance {
+				errs = append(errs, fmt.Errorf("pass %d: %v has radii %g and %g", i, a, r1, r2))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArcFormatSplit(t *testing.T) {
+	full := &Arc{Vector{1, 0, 0}, Vector{1, 0, 0}, Vector{0, 0, 0}, false}
+	assert.Len(t, ArcFormat{}.Split(full), 2, "full circles are split")
+
+	half := &Arc{Vector{1, 0, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, true}
+	assert.Len(t, ArcFormat{}.Split(half), 1)
+	parts := ArcFormat{Form: RadiusForm}.Split(half)
+	assert.Len(t, parts, 2, "half circles are split in the radius form")
+	for _, a := range parts {
+		assert.True(t, math.Abs(a.Sweep()) < math.Pi/2+1e-9)
+		assert.True(t, a.CW)
+	}
+	assert.Equal(t, half.From, parts[0].From)
+	assert.Equal(t, half.To, parts[1].To)
+}
+
+func TestArcFormatRadius(t *testing.T) {
+	tp := Toolpath{SafeZ: 5, Arcs: ArcFormat{Form: RadiusForm}, Passes: []Pass{{
+		Depth: 1,
+		Path:  Path{&Arc{Vector{0, 0, 0}, Vector{2, 0, 0}, Vector{1, 0, 0}, true}},
+	}}}
+	doc := tp.Gcode()
+	out := doc.Export(3)
+	assert.Contains(t, out, "R1")
+	assert.False(t, strings.Contains(out, "I"))
+
+	// the interpreter reads the radius form back
+	in, err := ReadGcode(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, in.Toolpath.Passes, 1)
+	_, to := in.Toolpath.Passes[0].Path.Move()
+	assertNear(t, Vector{2, 0, 0}, to)
+	for _, m := range in.Toolpath.Passes[0].Path {
+		a := m.(*Arc)
+		assertNear(t, Vector{1, 0, 0}, a.Center)
+	}
+}
+
+func TestArcFormatCheck(t *testing.T) {
+	tp := Toolpath{Passes: []Pass{{Path: Path{
+		&Arc{Vector{0, 0, 0}, Vector{2, 0, 0}, Vector{1, 0, 0}, true},
+		&Arc{Vector{2, 0, 0}, Vector{4.01, 0, 0}, Vector{3, 0, 0}, true},
+	}}}}
+	errs := ArcFormat{Tolerance: 0.002}.Check(tp)
+	assert.Len(t, errs, 1)
+
+	errs = ArcFormat{Form: RadiusForm, MaxSweep: math.Pi, Tolerance: 0.002}.Check(tp)
+	assert.Len(t, errs, 2, "a sweep too long is reported")
+}
+
+func TestArcFormatSplitHelix(t *testing.T) {
+	helix := &Arc{Vector{1, 0, 0}, Vector{1, 0, -4}, Vector{0, 0, 0}, false}
+	arcs := ArcFormat{Form: RadiusForm, MaxSweep: math.Pi / 2}.Split(helix)
+	assert.Len(t, arcs, 4)
+	for i, a := range arcs {
+		assert.InDelta(t, -float64(i), a.From.Z, 1e-9)
+		assert.InDelta(t, -float64(i+1), a.To.Z, 1e-9)
+	}
+}
+
+func TestArcFormatGcodeHelix(t *testing.T) {
+	helix := &Arc{Vector{1, 0, 0}, Vector{1, 0, -4}, Vector{0, 0, 0}, false}
+	tp := Toolpath{SafeZ: 5, Arcs: ArcFormat{Form: RadiusForm, MaxSweep: math.Pi / 2}, Passes: []Pass{{
+		Depth: 1,
+		Path:  Path{helix},
+	}}}
+	doc := tp.Gcode()
+	out := doc.Export(3)
+	arcs := []string{}
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(l, "G3") {
+			arcs = append(arcs, l)
+		}
+	}
+	// each part goes down a quarter of the helix
+	assert.Len(t, arcs, 4)
+	for i, l := range arcs {
+		assert.Contains(t, l, fmt.Sprintf("Z%d", -i-2))
+	}
+
+	in, err := ReadGcode(strings.NewReader(out))
+	assert.NoError(t, err)
+	_, to := in.Toolpath.Passes[0].Path.Move()
+	assertNear(t, Vector{1, 0, 0}, to)
+	assert.InDelta(t, -5, to.Z-in.Toolpath.Passes[0].Depth, 1e-9)
+}
@@ -0,0 +1,48 @@
+package main
+
+// This file contains the duplication of models, to cut several copies of a
+// part from the same sheet. Copies are deep copies: moves are modified in place
+// when they are reversed or transformed.
+
+// Clone returns a deep copy of the model
+func (m Model) Clone() Model {
+	c := make(Model, len(m))
+	for i, p := range m {
+		c[i] = p.Clone()
+	}
+	return c
+}
+
+// Grid returns n columns and rows copies of the model, their bounding boxes
+// separated by spacing.
+func (m Model) Grid(columns, rows int, spacing Vector) Model {
+	b := m.Bounds()
+	if b.Empty() {
+		return Model{}
+	}
+	pitch := b.Max.Diff(b.Min).Sum(spacing)
+	res := Model{}
+	for j := 0; j < rows; j++ {
+		for i := 0; i < columns; i++ {
+			c := m.Clone()
+			c.Transform(Translation(Vector{float64(i) * pitch.X, float64(j) * pitch.Y, 0}))
+			res = append(res, c...)
+		}
+	}
+	return res
+}
+
+// Polar returns count copies of the model, rotated around center by step
+// radians from each other.
+func (m Model) Polar(count int, center Vector, step float64) Model {
+	res := Model{}
+	for i := 0; i < count; i++ {
+		t := Translation(center.Multiply(-1)).
+			Then(Rotation(float64(i) * step)).
+			Then(Translation(center))
+		c := m.Clone()
+		c.Transform(t)
+		res = append(res, c...)
+	}
+	return res
+}
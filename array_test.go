@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrid(t *testing.T) {
+	m := Model{square(0, 0, 10)}
+	g := m.Grid(3, 2, Vector{5, 1, 0})
+	assert.Len(t, g, 6)
+	b := g.Bounds()
+	assertNear(t, Vector{0, 0, 0}, b.Min)
+	assertNear(t, Vector{40, 21, 0}, b.Max)
+
+	// copies do not share their moves
+	g[0].Reverse()
+	assert.True(t, m[0].Equal(square(0, 0, 10)))
+	assert.False(t, g[0].Equal(g[1]))
+}
+
+func TestPolar(t *testing.T) {
+	m := Model{Path{&Line{Vector{1, 0, 0}, Vector{2, 0, 0}}}}
+	p := m.Polar(4, Vector{0, 0, 0}, math.Pi/2)
+	assert.Len(t, p, 4)
+	from, to := p[1].Move()
+	assertNear(t, Vector{0, 1, 0}, from)
+	assertNear(t, Vector{0, 2, 0}, to)
+	from, _ = p[3].Move()
+	assertNear(t, Vector{0, -1, 0}, from)
+}
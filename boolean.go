@@ -0,0 +1,319 @@
+package main
+
+// This file contains boolean operations on regions. A region is a set of
+// closed paths, with outer boundaries running counter-clockwise and holes
+// running clockwise, so a point is inside the region when the winding number
+// of the paths around it is not zero.
+//
+// The boundaries of both operands are split where they intersect, then each
+// piece is kept or dropped depending on its position relative to the other
+// operand, and the remaining pieces are chained back into closed paths.
+
+import "math"
+
+// piece is a part of the boundary of a region, after splitting
+type piece struct {
+	move Move
+	used bool
+}
+
+// where a piece lies relative to a region
+const (
+	outside = iota
+	inside
+	sameEdge     // on the boundary, running in the same direction
+	oppositeEdge // on the boundary, running in the opposite direction
+)
+
+// orient returns a copy of the closed paths of a region, with outer boundaries
+// running counter-clockwise and holes running clockwise. Paths are nested by
+// containment, a path inside an odd number of others being a hole.
+func orient(paths []Path) []Path {
+	res := []Path{}
+	for _, p := range paths {
+		if p.IsClosed() && math.Abs(p.Area()) > TOLERANCE {
+			res = append(res, p.Clone())
+		}
+	}
+	depths := nesting(res)
+	for i, p := range res {
+		if (p.Area() > 0) != (depths[i]%2 == 0) {
+			p.Reverse()
+		}
+	}
+	return res
+}
+
+// nesting returns, for each closed path, the number of other paths around it.
+// Paths nested an odd number of times are holes.
+func nesting(paths []Path) []int {
+	depths := make([]int, len(paths))
+	for i, p := range paths {
+		pt := midpoint(p[0])
+		for j, o := range paths {
+			if i != j && winding([]Path{o}, pt) != 0 {
+				depths[i]++
+			}
+		}
+	}
+	return depths
+}
+
+// combine splits the boundaries of two oriented regions where they meet, and
+// keeps the pieces of a and b whose position relative to the other region is
+// accepted by keepA and keepB. Pieces of b are reversed if reverseB is set.
+func combine(a, b []Path, keepA, keepB func(int) bool, reverseB bool) []Path {
+	pa, pb := splitRegions(a, b)
+	kept := []Move{}
+	for _, p := range pa {
+		if keepA(classify(b, p)) {
+			kept = append(kept, p)
+		}
+	}
+	for _, p := range pb {
+		if keepB(classify(a, p)) {
+			if reverseB {
+				p.Reverse()
+			}
+			kept = append(kept, p)
+		}
+	}
+	return chain(kept)
+}
+
+// union returns the boundaries of the union of two oriented regions
+func union(a, b []Path) []Path {
+	return combine(a, b,
+		func(pos int) bool { return pos == outside || pos == sameEdge },
+		func(pos int) bool { return pos == outside },
+		false)
+}
+
+// intersection returns the boundaries of the intersection of two oriented
+// regions
+func intersection(a, b []Path) []Path {
+	return combine(a, b,
+		func(pos int) bool { return pos == inside || pos == sameEdge },
+		func(pos int) bool { return pos == inside },
+		false)
+}
+
+// difference returns the boundaries of a minus b, both oriented
+func difference(a, b []Path) []Path {
+	return combine(a, b,
+		func(pos int) bool { return pos == outside || pos == oppositeEdge },
+		func(pos int) bool { return pos == inside },
+		true)
+}
+
+// Union returns the closed paths bounding the area covered by a or b. Arcs are
+// kept as arcs.
+func Union(a, b []Path) []Path {
+	return union(orient(a), orient(b))
+}
+
+// Intersection returns the closed paths bounding the area covered by both a
+// and b
+func Intersection(a, b []Path) []Path {
+	return intersection(orient(a), orient(b))
+}
+
+// Difference returns the closed paths bounding the area covered by a and not
+// by b
+func Difference(a, b []Path) []Path {
+	return difference(orient(a), orient(b))
+}
+
+// Xor returns the closed paths bounding the area covered by either a or b, but
+// not both
+func Xor(a, b []Path) []Path {
+	a, b = orient(a), orient(b)
+	return union(difference(a, b), difference(b, a))
+}
+
+// unionAll returns the boundaries of the union of several regions
+func unionAll(regions [][]Path) []Path {
+	switch len(regions) {
+	case 0:
+		return nil
+	case 1:
+		return union(regions[0], nil)
+	}
+	// divide and conquer, so the intermediate results stay small
+	mid := len(regions) / 2
+	return union(unionAll(regions[:mid]), unionAll(regions[mid:]))
+}
+
+// splitRegions splits the moves of both regions where they intersect
+func splitRegions(a, b []Path) ([]Move, []Move) {
+	ma, mb := moves(a), moves(b)
+	ba, bb := make([]Box, len(ma)), make([]Box, len(mb))
+	for i, m := range ma {
+		ba[i] = bounds(m)
+	}
+	for i, m := range mb {
+		bb[i] = bounds(m)
+	}
+
+	pa := make([][]Vector, len(ma))
+	pb := make([][]Vector, len(mb))
+	for i, m1 := range ma {
+		for j, m2 := range mb {
+			if !ba[i].overlaps(bb[j]) {
+				continue
+			}
+			for _, p := range intersect(m1, m2) {
+				pa[i] = append(pa[i], p)
+				pb[j] = append(pb[j], p)
+			}
+		}
+	}
+
+	sa, sb := []Move{}, []Move{}
+	for i, m := range ma {
+		sa = append(sa, split(m, pa[i])...)
+	}
+	for j, m := range mb {
+		sb = append(sb, split(m, pb[j])...)
+	}
+	return sa, sb
+}
+
+// moves returns copies of all the moves of a region, dropping degenerate ones
+func moves(region []Path) []Move {
+	res := []Move{}
+	for _, p := range region {
+		for _, m := range p {
+			from, to := m.Move()
+			if _, ok := m.(*Arc); !ok && from.near(to) {
+				continue
+			}
+			res = append(res, clone(m))
+		}
+	}
+	return res
+}
+
+// classify returns the position of a piece relative to a region
+func classify(region []Path, m Move) int {
+	mid := midpoint(m)
+	for _, p := range region {
+		for _, o := range p {
+			if distance(o, mid) <= TOLERANCE*10 {
+				if tangent(m, mid).Dot(tangent(o, mid)) > 0 {
+					return sameEdge
+				}
+				return oppositeEdge
+			}
+		}
+	}
+	if winding(region, mid) != 0 {
+		return inside
+	}
+	return outside
+}
+
+// chain connects pieces end to end into closed paths. Pieces that cannot be
+// closed into a loop are dropped.
+func chain(pieces []Move) []Path {
+	grid := newGrid()
+	ps := make([]*piece, len(pieces))
+	for i, m := range pieces {
+		ps[i] = &piece{move: m}
+		from, _ := m.Move()
+		grid.add(from, ps[i])
+	}
+
+	res := []Path{}
+	for _, start := range ps {
+		if start.used {
+			continue
+		}
+		start.used = true
+		path := Path{start.move}
+		first, end := start.move.Move()
+		for !end.near(first) {
+			last := path[len(path)-1]
+			next := grid.find(end, tangent(last, end))
+			if next == nil {
+				break
+			}
+			next.used = true
+			snap(next.move, end)
+			path = append(path, next.move)
+			_, end = next.move.Move()
+		}
+		if !end.near(first) {
+			continue
+		}
+		closePath(path)
+		if math.Abs(path.Area()) > TOLERANCE {
+			res = append(res, path)
+		}
+	}
+	return res
+}
+
+// snap moves the start of a move to p, to remove rounding errors
+func snap(m Move, p Vector) {
+	switch m := m.(type) {
+	case *Line:
+		m.From = p
+	case *Arc:
+		m.From = p
+	}
+}
+
+// closePath moves the end of the last move of a path to its start
+func closePath(p Path) {
+	first, _ := p.Move()
+	switch m := p[len(p)-1].(type) {
+	case *Line:
+		m.To = first
+	case *Arc:
+		m.To = first
+	}
+}
+
+// grid is a spatial index of pieces by their starting point
+type grid map[[2]int64][]*piece
+
+const gridSize = 1e-3
+
+func newGrid() grid {
+	return grid{}
+}
+
+func cell(v Vector) [2]int64 {
+	return [2]int64{int64(math.Floor(v.X / gridSize)), int64(math.Floor(v.Y / gridSize))}
+}
+
+func (g grid) add(v Vector, p *piece) {
+	c := cell(v)
+	g[c] = append(g[c], p)
+}
+
+// find returns the unused piece starting near v, turning the most to the left
+// from the direction dir. Where boundaries touch at a single point, this keeps
+// them apart, instead of chaining them into a loop crossing itself.
+func (g grid) find(v, dir Vector) *piece {
+	c := cell(v)
+	var best *piece
+	bestTurn := 0.0
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, p := range g[[2]int64{c[0] + dx, c[1] + dy}] {
+				from, _ := p.move.Move()
+				if p.used || !from.near(v) {
+					continue
+				}
+				t := tangent(p.move, from)
+				turn := math.Atan2(dir.cross(t), dir.Dot(t))
+				if best == nil || turn > bestTurn {
+					best, bestTurn = p, turn
+				}
+			}
+		}
+	}
+	return best
+}
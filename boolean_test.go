@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntersection(t *testing.T) {
+	res := Intersection([]Path{square(0, 0, 2)}, []Path{square(1, 1, 2)})
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 1, totalArea(res), 1e-9)
+
+	// two discs overlap on a lens, bounded by two arcs
+	res = Intersection([]Path{circle(Vector{0, 0, 0}, 1)}, []Path{circle(Vector{1, 0, 0}, 1)})
+	assert.Len(t, res, 1)
+	lens := 2*math.Acos(0.5) - 0.5*math.Sqrt(3)
+	assert.InDelta(t, lens, totalArea(res), 1e-9)
+	for _, m := range res[0] {
+		_, ok := m.(*Arc)
+		assert.True(t, ok, "arcs are kept")
+	}
+}
+
+func TestDifference(t *testing.T) {
+	res := Difference([]Path{square(0, 0, 2)}, []Path{square(1, 1, 2)})
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 3, totalArea(res), 1e-9)
+
+	// a disc inside a square makes a hole
+	res = Difference([]Path{square(0, 0, 4)}, []Path{circle(Vector{2, 2, 0}, 1)})
+	assert.Len(t, res, 2)
+	assert.InDelta(t, 16-math.Pi, totalArea(res), 1e-9)
+
+	// nothing is left of a square inside another
+	res = Difference([]Path{square(1, 1, 1)}, []Path{square(0, 0, 4)})
+	assert.Len(t, res, 0)
+}
+
+func TestXor(t *testing.T) {
+	res := Xor([]Path{square(0, 0, 2)}, []Path{square(1, 1, 2)})
+	assert.Len(t, res, 2)
+	assert.InDelta(t, 6, totalArea(res), 1e-9)
+}
+
+func TestBooleanSharedEdges(t *testing.T) {
+	a, b := []Path{square(0, 0, 1)}, []Path{square(1, 0, 1)}
+	assert.Len(t, Intersection(a, b), 0, "squares sharing an edge do not intersect")
+	res := Difference(a, b)
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 1, totalArea(res), 1e-9)
+	res = Xor(a, b)
+	assert.InDelta(t, 2, totalArea(res), 1e-9)
+
+	// squares sharing part of an edge, in the same direction
+	res = Difference([]Path{square(0, 0, 2)}, []Path{square(0, 0, 1)})
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 3, totalArea(res), 1e-9)
+	res = Intersection([]Path{square(0, 0, 2)}, []Path{square(0, 0, 1)})
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 1, totalArea(res), 1e-9)
+}
+
+func TestBooleanTangency(t *testing.T) {
+	// squares touching at a corner stay two loops
+	res := Union([]Path{square(0, 0, 1)}, []Path{square(1, 1, 1)})
+	assert.Len(t, res, 2)
+	assert.InDelta(t, 2, totalArea(res), 1e-9)
+
+	// a disc tangent to the inside of a square
+	res = Difference([]Path{square(0, 0, 2)}, []Path{circle(Vector{1, 1, 0}, 1)})
+	assert.InDelta(t, 4-math.Pi, totalArea(res), 1e-9)
+	res = Intersection([]Path{square(0, 0, 2)}, []Path{circle(Vector{1, 1, 0}, 1)})
+	assert.InDelta(t, math.Pi, totalArea(res), 1e-9)
+
+	// a line tangent to a disc from the outside
+	res = Union([]Path{square(0, 0, 2)}, []Path{circle(Vector{1, 3, 0}, 1)})
+	assert.InDelta(t, 4+math.Pi, totalArea(res), 1e-9)
+}
@@ -0,0 +1,250 @@
+package main
+
+// This file contains the cleanup of imported models: some programs export
+// duplicated entities, zero-length lines, or curves made of many tiny
+// segments, which make the tool go over the same place several times, or
+// slow down the machine.
+
+import "math"
+
+// Cleanup describes the simplification of a model
+type Cleanup struct {
+	Tolerance float64 // maximum distance between the simplified and the original paths
+}
+
+// tolerance returns the distance under which points are considered aligned
+func (c Cleanup) tolerance() float64 {
+	return math.Max(c.Tolerance, TOLERANCE)
+}
+
+// empty returns true if the move has no length
+func empty(m Move) bool {
+	switch m := m.(type) {
+	case *Line:
+		return m.From.near(m.To) && m.From.Z == m.To.Z
+	case *Arc:
+		return m.radius() <= TOLERANCE
+	case Path:
+		return len(m) == 0
+	}
+	return false
+}
+
+// aligned returns true if p lies on the infinite line going through l
+func aligned(l *Line, p Vector) bool {
+	d := l.To.Diff(l.From)
+	v := p.Diff(l.From)
+	return math.Abs(d.X*v.Y-d.Y*v.X) <= TOLERANCE*d.Norm()
+}
+
+// flat returns true if both lines are at the same, constant height
+func flat(l, o *Line) bool {
+	return l.From.Z == l.To.Z && o.From.Z == l.From.Z && o.To.Z == l.From.Z
+}
+
+// uncovered returns the parts of m that are not covered by o
+func uncovered(m, o Move) []Move {
+	r := clone(o)
+	r.Reverse()
+	if m.Equal(o) || m.Equal(r) {
+		return nil
+	}
+
+	var pts []Vector
+	var on func(p Vector) bool
+	switch m := m.(type) {
+	case *Line:
+		o, ok := o.(*Line)
+		if !ok || !flat(m, o) || !aligned(m, o.From) || !aligned(m, o.To) {
+			return []Move{m}
+		}
+		for _, p := range []Vector{o.From, o.To} {
+			if onLine(m, p) {
+				pts = append(pts, p)
+			}
+		}
+		on = func(p Vector) bool { return onLine(o, p) }
+	case *Arc:
+		o, ok := o.(*Arc)
+		if !ok || !m.Center.near(o.Center) || math.Abs(m.radius()-o.radius()) > TOLERANCE {
+			return []Move{m}
+		}
+		for _, p := range []Vector{o.From, o.To} {
+			if onArc(m, p) {
+				pts = append(pts, p)
+			}
+		}
+		on = func(p Vector) bool { return onArc(o, p) }
+	default:
+		return []Move{m}
+	}
+
+	// split returns m itself when there is nothing to cut
+	parts := []Move{}
+	for _, part := range split(m, pts) {
+		if !on(midpoint(part)) {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// unique removes zero-length moves, and the moves or parts of moves already
+// found earlier in the model. Paths are cut where moves are removed.
+func (c Cleanup) unique(m Model, stats *Stats) Model {
+	kept := []Move{}
+	boxes := []Box{}
+	res := Model{}
+	cut := false
+	for _, p := range m {
+		run := Path{}
+		flush := func() {
+			if len(run) > 0 {
+				res = append(res, run)
+			}
+			run = Path{}
+		}
+		for _, mv := range p {
+			if empty(mv) {
+				stats.Empty++
+				cut = true
+				flush()
+				continue
+			}
+			parts := []Move{mv}
+			b := bounds(mv)
+			for i, o := range kept {
+				if !b.overlaps(boxes[i]) {
+					continue
+				}
+				next := []Move{}
+				for _, part := range parts {
+					next = append(next, uncovered(part, o)...)
+				}
+				parts = next
+			}
+			if len(parts) == 1 && parts[0] == mv {
+				run = append(run, mv)
+			} else {
+				stats.Discarded++
+				cut = true
+				for _, part := range parts {
+					flush()
+					run = append(run, part)
+				}
+				flush()
+			}
+			kept = append(kept, mv)
+			boxes = append(boxes, b)
+		}
+		flush()
+	}
+	if cut {
+		// join the pieces again
+		res.Merge()
+	}
+	return res
+}
+
+// cocircular returns the arc made of a followed by b, if they lie on the same
+// circle and turn the same way. Full circles are kept in two parts.
+func cocircular(a, b Move) *Arc {
+	a1, ok1 := a.(*Arc)
+	a2, ok2 := b.(*Arc)
+	if !ok1 || !ok2 || a1.CW != a2.CW || !a1.Center.near(a2.Center) ||
+		math.Abs(a1.radius()-a2.radius()) > TOLERANCE || a2.To.near(a1.From) ||
+		math.Abs(a1.Sweep())+math.Abs(a2.Sweep()) >= 2*math.Pi {
+		return nil
+	}
+	return &Arc{a1.From, a2.To, a1.Center, a1.CW}
+}
+
+// simplify returns the indexes of the points kept by the Douglas-Peucker
+// algorithm, between first and last
+func (c Cleanup) simplify(pts []Vector, first, last int) []int {
+	chord := &Line{pts[first], pts[last]}
+	far, dist := -1, c.tolerance()
+	for i := first + 1; i < last; i++ {
+		d := distance(chord, pts[i])
+		if pts[first].near(pts[last]) {
+			// closed run, the chord is a point
+			d = pts[i].Diff(pts[first]).Norm()
+		}
+		if d > dist {
+			far, dist = i, d
+		}
+	}
+	if far < 0 {
+		return []int{first, last}
+	}
+	left := c.simplify(pts, first, far)
+	return append(left, c.simplify(pts, far, last)[1:]...)
+}
+
+// lines simplifies a run of consecutive lines. Lines that are kept are not
+// copied, and runs changing height are left alone.
+func (c Cleanup) lines(run []Move, stats *Stats) []Move {
+	if len(run) < 2 {
+		return run
+	}
+	pts := make([]Vector, 0, len(run)+1)
+	pts = append(pts, run[0].(*Line).From)
+	for _, m := range run {
+		pts = append(pts, m.(*Line).To)
+		if pts[len(pts)-1].Z != pts[0].Z {
+			return run
+		}
+	}
+	idx := c.simplify(pts, 0, len(pts)-1)
+	res := []Move{}
+	for k := 1; k < len(idx); k++ {
+		i, j := idx[k-1], idx[k]
+		if j == i+1 {
+			res = append(res, run[i])
+		} else {
+			res = append(res, &Line{pts[i], pts[j]})
+		}
+	}
+	stats.Merged += len(run) - len(res)
+	return res
+}
+
+// Path returns the path with cocircular arcs merged, and runs of lines
+// simplified.
+func (c Cleanup) Path(p Path, stats *Stats) Path {
+	arcs := Path{}
+	for _, m := range p {
+		if n := len(arcs); n > 0 {
+			if a := cocircular(arcs[n-1], m); a != nil {
+				arcs[n-1] = a
+				stats.Merged++
+				continue
+			}
+		}
+		arcs = append(arcs, m)
+	}
+
+	res := Path{}
+	run := []Move{}
+	for _, m := range arcs {
+		if _, ok := m.(*Line); ok {
+			run = append(run, m)
+			continue
+		}
+		res = append(res, c.lines(run, stats)...)
+		run = run[:0]
+		res = append(res, m)
+	}
+	return append(res, c.lines(run, stats)...)
+}
+
+// Model removes zero-length moves and duplicates from the model, merges
+// collinear lines and cocircular arcs, and simplifies paths within the
+// tolerance. The changes are counted in stats.
+func (c Cleanup) Model(m Model, stats *Stats) Model {
+	m = c.unique(m, stats)
+	for i, p := range m {
+		m[i] = c.Path(p, stats)
+	}
+	return m
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupDuplicates(t *testing.T) {
+	stats := Stats{}
+	m := Model{
+		Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}},
+		Path{&Line{Vector{10, 0, 0}, Vector{0, 0, 0}}},  // reversed duplicate
+		Path{&Line{Vector{5, 0, 0}, Vector{15, 0, 0}}},  // overlapping
+		Path{&Line{Vector{15, 0, 0}, Vector{15, 0, 0}}}, // zero length
+		Path{&Arc{Vector{1, 0, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, false}},
+		Path{&Arc{Vector{0, 1, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, false}}, // covered by the previous arc
+	}
+	m = Cleanup{}.Model(m, &stats)
+	assert.Equal(t, 3, stats.Discarded)
+	assert.Equal(t, 1, stats.Empty)
+	assert.Len(t, m, 2)
+	// the overlapping line keeps its own part, and is joined to the first one
+	assert.Equal(t, Path{&Line{Vector{0, 0, 0}, Vector{15, 0, 0}}}, m[0])
+	assert.Equal(t, 1, stats.Merged)
+	assert.Len(t, m[1], 1)
+}
+
+func TestCleanupMerge(t *testing.T) {
+	stats := Stats{}
+	c := circle(Vector{0, 0, 0}, 2)
+	quarters := Path{
+		&Arc{Vector{1, 0, 0}, Vector{0, 1, 0}, Vector{0, 0, 0}, false},
+		&Arc{Vector{0, 1, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, false},
+		&Line{Vector{-1, 0, 0}, Vector{-1, -1, 0}},
+		&Line{Vector{-1, -1, 0}, Vector{-1, -2, 0}},
+		&Line{Vector{-1, -2, 0}, Vector{0, -2, 0}},
+	}
+	m := Cleanup{}.Model(Model{c, quarters}, &stats)
+	assert.Equal(t, c, m[0], "full circles are kept in two halves")
+	assert.Equal(t, Path{
+		&Arc{Vector{1, 0, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, false},
+		&Line{Vector{-1, 0, 0}, Vector{-1, -2, 0}},
+		&Line{Vector{-1, -2, 0}, Vector{0, -2, 0}},
+	}, m[1])
+	assert.Equal(t, 2, stats.Merged)
+	assert.Equal(t, 0, stats.Discarded)
+}
+
+func TestCleanupSimplify(t *testing.T) {
+	// a noisy line, and a spike
+	p := polygon(Vector{0, 0, 0}, Vector{1, 0.01, 0}, Vector{2, -0.01, 0}, Vector{3, 0, 0},
+		Vector{4, 1, 0}, Vector{5, 0, 0}, Vector{5, -1, 0})
+	p = p[:len(p)-1]
+
+	stats := Stats{}
+	m := Cleanup{}.Model(Model{p.Clone()}, &stats)
+	assert.Len(t, m[0], 6, "nothing is aligned without tolerance")
+
+	stats = Stats{}
+	m = Cleanup{Tolerance: 0.05}.Model(Model{p.Clone()}, &stats)
+	assert.Equal(t, Path{
+		&Line{Vector{0, 0, 0}, Vector{3, 0, 0}},
+		&Line{Vector{3, 0, 0}, Vector{4, 1, 0}},
+		&Line{Vector{4, 1, 0}, Vector{5, 0, 0}},
+		&Line{Vector{5, 0, 0}, Vector{5, -1, 0}},
+	}, m[0])
+	assert.Equal(t, 2, stats.Merged)
+}
+
+func TestCleanupKeepsModel(t *testing.T) {
+	m := Model{square(0, 0, 1), circle(Vector{5, 5, 0}, 1)}
+	stats := Stats{}
+	res := Cleanup{}.Model(m.Clone(), &stats)
+	assert.Equal(t, m, res)
+	assert.Equal(t, Stats{}, stats)
+}
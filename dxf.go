@@ -6,6 +6,7 @@ package main
 import (
 	"io"
 	"math"
+	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/rpaloschi/dxf-go/core"
@@ -13,48 +14,82 @@ import (
 	"github.com/rpaloschi/dxf-go/entities"
 )
 
-type Importer struct {
+func init() {
+	RegisterFormat(Format{
+		Name:       "dxf",
+		Extensions: []string{".dxf"},
+		Magic:      isDXF,
+		New:        func() Importer { return NewDXFImporter() },
+	})
+}
+
+// isDXF returns true if the header looks like an ASCII DXF file, ie it starts
+// with a comment (group code 999) or with the opening of a section.
+func isDXF(head []byte) bool {
+	fields := strings.Fields(string(head))
+	if len(fields) < 2 {
+		return false
+	}
+	return fields[0] == "999" || (fields[0] == "0" && fields[1] == "SECTION")
+}
+
+type DXFImporter struct {
+	Stats
 	Precision int
-	Imported  int // number of imported entities
-	Ignored   int // number of ignored entities
-	Discarded int // number of discarded entities (duplicates)
-	Model     *Model
+	layers    map[string]*Model
 }
 
-func NewImporter() *Importer {
-	return &Importer{
+func NewDXFImporter() *DXFImporter {
+	return &DXFImporter{
 		Precision: 3,
-		Model:     &Model{},
+		layers:    map[string]*Model{},
 	}
 }
 
-func (im *Importer) Import(stream io.Reader) error {
+func (im *DXFImporter) Import(stream io.Reader) (*Model, Stats, error) {
 	doc, err := document.DxfDocumentFromStream(stream)
 	if err != nil {
-		return err
+		return nil, im.Stats, err
 	}
 
 	Log.Println("Importing entities")
 	for _, e := range doc.Entities.Entities {
 		im.ImportEntity(e)
 	}
-	im.Model.Merge()
+	for _, m := range im.layers {
+		m.Merge()
+	}
+	return flatten(im.layers), im.Stats, nil
+}
 
-	Log.Println("Imported entities: ", im.Imported)
-	Log.Println("Ignored entities:  ", im.Ignored)
-	Log.Println("Discarded entities:", im.Discarded)
+// Layers returns the imported models, by the layers of the entities
+func (im *DXFImporter) Layers() map[string]*Model {
+	return im.layers
+}
 
-	return nil
+// append adds a move to a layer, entities without layer going on DefaultLayer
+func (im *DXFImporter) append(layer string, mo Move) {
+	if layer == "" {
+		layer = DefaultLayer
+	}
+	m, ok := im.layers[layer]
+	if !ok {
+		m = &Model{}
+		im.layers[layer] = m
+	}
+	m.Append(mo)
+	im.Imported++
 }
 
-func (im *Importer) ImportPoint(p core.Point) Vector {
+func (im *DXFImporter) ImportPoint(p core.Point) Vector {
 	pre := math.Pow10(im.Precision)
 	x := math.Floor(p.X*pre) / pre
 	y := math.Floor(p.Y*pre) / pre
-	return Vector{x, y}
+	z := math.Floor(p.Z*pre) / pre
+	return Vector{x, y, z}
 }
 
-func (im *Importer) ImportEntity(e entities.Entity) {
+func (im *DXFImporter) ImportEntity(e entities.Entity) {
 	switch e := e.(type) {
 	case *entities.Line:
 		im.ImportLine(e)
@@ -74,25 +109,23 @@ func (im *Importer) ImportEntity(e entities.Entity) {
 	}
 }
 
-func (im *Importer) ImportLine(e *entities.Line) {
+func (im *DXFImporter) ImportLine(e *entities.Line) {
 	from := im.ImportPoint(e.Start)
 	to := im.ImportPoint(e.End)
-	im.Model.Append(&Line{from, to})
-	im.Imported++
+	im.append(e.LayerName, &Line{from, to})
 }
 
-func (im *Importer) ImportPolyline(e *entities.Polyline) {
+func (im *DXFImporter) ImportPolyline(e *entities.Polyline) {
 	p := make(Path, 0, len(e.Vertices)-1)
 	for i := 0; i < len(e.Vertices)-1; i++ {
 		from := im.ImportPoint(e.Vertices[i].Location)
 		to := im.ImportPoint(e.Vertices[i+1].Location)
 		p = append(p, &Line{from, to})
 	}
-	im.Model.Append(p)
-	im.Imported++
+	im.append(e.LayerName, p)
 }
 
-func (im *Importer) ImportLWPolyline(e *entities.LWPolyline) {
+func (im *DXFImporter) ImportLWPolyline(e *entities.LWPolyline) {
 	pts := e.Points
 	p := make(Path, 0, len(pts)-1)
 	for i := 0; i < len(pts)-1; i++ {
@@ -112,11 +145,10 @@ func (im *Importer) ImportLWPolyline(e *entities.LWPolyline) {
 			p = append(p, &Arc{startPoint, endPoint, center, false})
 		}
 	}
-	im.Model.Append(p)
-	im.Imported++
+	im.append(e.LayerName, p)
 }
 
-func (im *Importer) ImportArc(e *entities.Arc) {
+func (im *DXFImporter) ImportArc(e *entities.Arc) {
 	center := im.ImportPoint(e.Center)
 	startAngle := deg2rad(e.StartAngle)
 	endAngle := deg2rad(e.EndAngle)
@@ -126,25 +158,23 @@ func (im *Importer) ImportArc(e *entities.Arc) {
 	radius := e.Radius
 	startPoint := pol2car(startAngle, radius).Sum(center)
 	endPoint := pol2car(endAngle, radius).Sum(center)
-	im.Model.Append(&Arc{startPoint, endPoint, center, false})
-	im.Imported++
+	im.append(e.LayerName, &Arc{startPoint, endPoint, center, false})
 }
 
 // import a circle as two 180 degrees arcs
-func (im *Importer) ImportCircle(e *entities.Circle) {
+func (im *DXFImporter) ImportCircle(e *entities.Circle) {
 	center := im.ImportPoint(e.Center)
 	radius := e.Radius
-	a := center.Sum(Vector{radius, 0})
-	b := center.Sum(Vector{-radius, 0})
+	a := center.Sum(Vector{radius, 0, 0})
+	b := center.Sum(Vector{-radius, 0, 0})
 	p := Path{
 		&Arc{a, b, center, false},
 		&Arc{b, a, center, false},
 	}
-	im.Model.Append(p)
-	im.Imported++
+	im.append(e.LayerName, p)
 }
 
-func (im *Importer) ImportSpline(e *entities.Spline) {
+func (im *DXFImporter) ImportSpline(e *entities.Spline) {
 	// FIXME
 	s := &Spline{}
 	s.Degree = e.Degree
@@ -164,8 +194,7 @@ func (im *Importer) ImportSpline(e *entities.Spline) {
 	}
 
 	// FIXME
-	// im.Model.Append(s)
-	// im.Imported++
+	// im.append(e.LayerName, s)
 	spew.Dump(s)
 	// min := float64(s.Knots[0])
 	// max := float64(s.Knots[len(s.Knots)-1])
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rpaloschi/dxf-go/core"
+	"github.com/rpaloschi/dxf-go/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDXFLayers(t *testing.T) {
+	im := NewDXFImporter()
+	im.ImportEntity(&entities.Line{
+		BaseEntity: entities.BaseEntity{LayerName: "cut"},
+		Start:      core.Point{X: 0, Y: 0},
+		End:        core.Point{X: 10, Y: 0},
+	})
+	im.ImportEntity(&entities.Circle{
+		BaseEntity: entities.BaseEntity{LayerName: "holes"},
+		Center:     core.Point{X: 5, Y: 5},
+		Radius:     1,
+	})
+	im.ImportEntity(&entities.Line{
+		Start: core.Point{X: 0, Y: 10},
+		End:   core.Point{X: 10, Y: 10},
+	})
+	layers := im.Layers()
+	assert.Len(t, layers, 3)
+	assert.Len(t, *layers["cut"], 1)
+	assert.Len(t, *layers["holes"], 1)
+	assert.Len(t, *layers[DefaultLayer], 1, "entities without layer are on the default layer")
+	assert.Equal(t, 3, im.Imported)
+}
+
+func TestDXFDrillCircle(t *testing.T) {
+	im := NewDXFImporter()
+	im.ImportEntity(&entities.Circle{
+		Center: core.Point{X: 5, Y: 7},
+		Radius: 2,
+	})
+	op := Operation{Kind: Drilling, Tool: 3, Depth: 4}
+	passes, err := op.Passes(*im.Layers()[DefaultLayer])
+	assert.NoError(t, err)
+	assert.Len(t, passes, 1)
+	d, ok := passes[0].Path[0].(*Drill)
+	assert.True(t, ok)
+	assert.Equal(t, Vector{5, 7, 0}, d.At, "circles are drilled at their center")
+	assert.Equal(t, 3.0, d.Diameter)
+}
+
+func TestDXFPointHeight(t *testing.T) {
+	im := NewDXFImporter()
+	assert.Equal(t, Vector{1, 2, -3}, im.ImportPoint(core.Point{X: 1, Y: 2, Z: -3}))
+}
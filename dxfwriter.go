@@ -0,0 +1,219 @@
+package main
+
+// This file contains the code to export models and toolpaths to the DXF
+// format, so they can be checked in a CAD program before cutting. Files are
+// written in the R12 version, which needs no handles and is read by most
+// programs.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// DXFWriter writes entities in the DXF format. Errors are kept until Flush.
+type DXFWriter struct {
+	Precision int
+	w         *bufio.Writer
+	err       error
+}
+
+func NewDXFWriter(w io.Writer, precision int) *DXFWriter {
+	return &DXFWriter{
+		Precision: precision,
+		w:         bufio.NewWriter(w),
+	}
+}
+
+// group writes a group code followed by its value
+func (dw *DXFWriter) group(code int, value interface{}) {
+	if dw.err != nil {
+		return
+	}
+	var s string
+	switch v := value.(type) {
+	case float64:
+		s = formatFloat(v, dw.Precision)
+	default:
+		s = fmt.Sprint(v)
+	}
+	_, dw.err = fmt.Fprintf(dw.w, "%3d\n%s\n", code, s)
+}
+
+// point writes a point raised by z, with its group code for X (10, 11...)
+func (dw *DXFWriter) point(code int, v Vector, z float64) {
+	dw.group(code, v.X)
+	dw.group(code+10, v.Y)
+	dw.group(code+20, v.Z+z)
+}
+
+// Header writes the beginning of the file, up to the entities
+func (dw *DXFWriter) Header(layers []string) {
+	dw.group(0, "SECTION")
+	dw.group(2, "HEADER")
+	dw.group(9, "$ACADVER")
+	dw.group(1, "AC1009")
+	dw.group(0, "ENDSEC")
+
+	dw.group(0, "SECTION")
+	dw.group(2, "TABLES")
+	dw.group(0, "TABLE")
+	dw.group(2, "LAYER")
+	dw.group(70, len(layers))
+	for i, name := range layers {
+		dw.group(0, "LAYER")
+		dw.group(2, name)
+		dw.group(70, 0)
+		dw.group(62, i%255+1) // color
+		dw.group(6, "CONTINUOUS")
+	}
+	dw.group(0, "ENDTAB")
+	dw.group(0, "ENDSEC")
+
+	dw.group(0, "SECTION")
+	dw.group(2, "ENTITIES")
+}
+
+// Footer ends the file
+func (dw *DXFWriter) Footer() {
+	dw.group(0, "ENDSEC")
+	dw.group(0, "EOF")
+}
+
+// Path writes a path raised by z. Flat closed paths become closed polylines,
+// the moves of other paths are written separately.
+func (dw *DXFWriter) Path(layer string, p Path, z float64) {
+	if p.IsClosed() && len(p) > 1 && level(p) {
+		dw.Polyline(layer, p, z)
+		return
+	}
+	for _, m := range p {
+		dw.Move(layer, m, z)
+	}
+}
+
+// level returns true if all the points of the path are at the same height
+func level(p Path) bool {
+	pts := p.Points()
+	for _, v := range pts[1:] {
+		if math.Abs(v.Z-pts[0].Z) > EPSILON {
+			return false
+		}
+	}
+	return true
+}
+
+// Polyline writes a flat closed path as a closed POLYLINE raised by z, arcs
+// being converted to bulges.
+func (dw *DXFWriter) Polyline(layer string, p Path, z float64) {
+	from, _ := p.Move()
+	dw.group(0, "POLYLINE")
+	dw.group(8, layer)
+	dw.group(66, 1) // vertices follow
+	dw.point(10, Vector{0, 0, from.Z}, z)
+	dw.group(70, 1) // closed
+	for _, m := range p {
+		from, _ := m.Move()
+		dw.group(0, "VERTEX")
+		dw.group(8, layer)
+		dw.point(10, from, z)
+		if a, ok := m.(*Arc); ok {
+			start := a.startAngle()
+			_, _, bulge := arcToBulge(a.Center, a.radius(), start, start+a.Sweep())
+			dw.group(42, bulge)
+		}
+	}
+	dw.group(0, "SEQEND")
+	dw.group(8, layer)
+}
+
+// Move writes a single move raised by z. Arcs are drawn at the height of
+// their start, as DXF arcs can not be helical.
+func (dw *DXFWriter) Move(layer string, m Move, z float64) {
+	switch m := m.(type) {
+	case *Line:
+		dw.group(0, "LINE")
+		dw.group(8, layer)
+		dw.point(10, m.From, z)
+		dw.point(11, m.To, z)
+	case *Arc:
+		center := Vector{m.Center.X, m.Center.Y, m.From.Z}
+		if m.From.near(m.To) {
+			dw.group(0, "CIRCLE")
+			dw.group(8, layer)
+			dw.point(10, center, z)
+			dw.group(40, m.radius())
+			return
+		}
+		// DXF arcs always run counter-clockwise
+		start := m.startAngle()
+		end := start + m.Sweep()
+		if m.CW {
+			start, end = end, start
+		}
+		dw.group(0, "ARC")
+		dw.group(8, layer)
+		dw.point(10, center, z)
+		dw.group(40, m.radius())
+		dw.group(50, normalizeAngle(start)*180/math.Pi)
+		dw.group(51, normalizeAngle(end)*180/math.Pi)
+	case *Drill:
+		if m.Diameter > 0 {
+			dw.group(0, "CIRCLE")
+			dw.group(8, layer)
+			dw.point(10, m.At, z)
+			dw.group(40, m.Diameter/2)
+		} else {
+			dw.group(0, "POINT")
+			dw.group(8, layer)
+			dw.point(10, m.At, z)
+		}
+	case Path:
+		dw.Path(layer, m, z)
+	default:
+		Log.Printf("Cannot export move of type %T to DXF\n", m)
+	}
+}
+
+// Flush writes buffered data and returns the first error encountered
+func (dw *DXFWriter) Flush() error {
+	if dw.err != nil {
+		return dw.err
+	}
+	return dw.w.Flush()
+}
+
+// DXF writes the model on a single layer
+func (m Model) DXF(w io.Writer, precision int) error {
+	dw := NewDXFWriter(w, precision)
+	dw.Header([]string{DefaultLayer})
+	for _, p := range m {
+		dw.Path(DefaultLayer, p, 0)
+	}
+	dw.Footer()
+	return dw.Flush()
+}
+
+// DXF writes the toolpath, with one layer per operation and depth of pass.
+// Each pass is drawn at its depth, below the heights of its moves.
+func (tp Toolpath) DXF(w io.Writer, precision int) error {
+	dw := NewDXFWriter(w, precision)
+	names := make([]string, len(tp.Passes))
+	layers := []string{}
+	seen := map[string]bool{}
+	for i, p := range tp.Passes {
+		names[i] = fmt.Sprintf("%s_%s", p.Operation, strconv.FormatFloat(p.Depth, 'f', -1, 64))
+		if !seen[names[i]] {
+			seen[names[i]] = true
+			layers = append(layers, names[i])
+		}
+	}
+	dw.Header(layers)
+	for i, p := range tp.Passes {
+		dw.Path(names[i], p.Path, -p.Depth)
+	}
+	dw.Footer()
+	return dw.Flush()
+}
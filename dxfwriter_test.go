@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDXFClosedPath(t *testing.T) {
+	var buf bytes.Buffer
+	m := Model{circle(Vector{0, 0, 0}, 1)}
+	assert.NoError(t, m.DXF(&buf, 3))
+	out := buf.String()
+	assert.Contains(t, out, "AC1009")
+	assert.Contains(t, out, "POLYLINE\n  8\n0\n 66\n1\n 10\n0.000\n 20\n0.000\n 30\n0.000\n 70\n1\n")
+	assert.Equal(t, 2, strings.Count(out, "  0\nVERTEX\n"))
+	assert.Contains(t, out, "  0\nSEQEND\n")
+	// half circles have a bulge of 1
+	assert.Equal(t, 2, strings.Count(out, " 42\n1.000\n"))
+}
+
+func TestDXFOpenPath(t *testing.T) {
+	var buf bytes.Buffer
+	m := Model{Path{
+		&Line{Vector{0, 0, 0}, Vector{1, 0, 0}},
+		&Arc{Vector{1, 0, 0}, Vector{2, 1, 0}, Vector{1, 1, 0}, true},
+	}}
+	assert.NoError(t, m.DXF(&buf, 1))
+	out := buf.String()
+	assert.Contains(t, out, "  0\nLINE\n")
+	// clockwise arcs are written counter-clockwise, from 0° to 270°
+	assert.Contains(t, out, "  0\nARC\n  8\n0\n 10\n1.0\n 20\n1.0\n 30\n0.0\n 40\n1.0\n 50\n0.0\n 51\n270.0\n")
+}
+
+func TestDXFToolpathLayers(t *testing.T) {
+	var buf bytes.Buffer
+	op := Operation{Name: "cut", Kind: Engrave, Depth: 2, PassDepth: 1}
+	passes, err := op.Passes(Model{circle(Vector{0, 0, 0}, 1)})
+	assert.NoError(t, err)
+	tp := Toolpath{SafeZ: 5, Passes: passes}
+	assert.NoError(t, tp.DXF(&buf, 3))
+	out := buf.String()
+	assert.Contains(t, out, "LAYER\n  2\ncut_1\n")
+	assert.Contains(t, out, "LAYER\n  2\ncut_2\n")
+	assert.Contains(t, out, "VERTEX\n  8\ncut_2\n 10\n1.000\n 20\n0.000\n 30\n-2.000\n")
+}
+
+func TestDXFFullCircle(t *testing.T) {
+	var buf bytes.Buffer
+	m := Model{Path{&Arc{Vector{1, 0, 0}, Vector{1, 0, 0}, Vector{0, 0, 0}, false}}}
+	assert.NoError(t, m.DXF(&buf, 1))
+	out := buf.String()
+	assert.Contains(t, out, "  0\nCIRCLE\n  8\n0\n 10\n0.0\n 20\n0.0\n 30\n0.0\n 40\n1.0\n")
+	assert.False(t, strings.Contains(out, "ARC"))
+}
+
+func TestDXFHeights(t *testing.T) {
+	var buf bytes.Buffer
+	ramp := Path{
+		&Line{Vector{0, 0, 0}, Vector{1, 0, -0.5}},
+		&Line{Vector{1, 0, -0.5}, Vector{0, 0, -1}},
+	}
+	tp := Toolpath{SafeZ: 5, Passes: []Pass{{Operation: "ramp", Depth: 2, Path: ramp}}}
+	assert.NoError(t, tp.DXF(&buf, 1))
+	out := buf.String()
+	// the heights of the moves are kept, below the depth of the pass
+	assert.Contains(t, out, "  0\nLINE\n  8\nramp_2\n 10\n0.0\n 20\n0.0\n 30\n-2.0\n 11\n1.0\n 21\n0.0\n 31\n-2.5\n")
+	assert.Contains(t, out, " 30\n-2.5\n 11\n0.0\n 21\n0.0\n 31\n-3.0\n")
+}
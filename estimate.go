@@ -0,0 +1,249 @@
+package main
+
+// This file contains the estimation of the machining time of a toolpath. The
+// toolpath is broken into straight blocks, arcs being segmented, and their
+// speed is planned the way grbl does it: each axis has a maximum rate and
+// acceleration, and the speed at the junction between two blocks is limited
+// by the junction deviation.
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/joushou/gocnc/gcode"
+)
+
+// Machine describes the kinematics of the machine
+type Machine struct {
+	MaxRate           [3]float64 // maximum rate of each axis, in mm/min (grbl $110-$112)
+	Accel             [3]float64 // maximum acceleration of each axis, in mm/s² (grbl $120-$122)
+	JunctionDeviation float64    // in mm (grbl $11)
+	ArcTolerance      float64    // in mm (grbl $12)
+	Feed              float64    // feed rate of passes without one, in mm/min
+}
+
+// DefaultMachine returns the settings of a typical hobby router
+func DefaultMachine() Machine {
+	return Machine{
+		MaxRate:           [3]float64{5000, 5000, 1000},
+		Accel:             [3]float64{250, 250, 100},
+		JunctionDeviation: 0.01,
+		ArcTolerance:      0.002,
+		Feed:              1000,
+	}
+}
+
+// Estimate is the machining time of a toolpath
+type Estimate struct {
+	Total  time.Duration
+	Passes []time.Duration // time spent on each pass, including the moves to reach it
+}
+
+// plannerBlock is a straight move of the machine
+type plannerBlock struct {
+	pass     int
+	unit     [3]float64 // direction
+	length   float64    // in mm
+	nominal  float64    // maximum speed, in mm/s
+	accel    float64    // maximum acceleration, in mm/s²
+	entryMax float64    // maximum speed at the start of the block
+	entry    float64    // planned speed at the start of the block
+	dwell    float64    // pause before the block, in seconds
+}
+
+// planner turns moves into blocks
+type planner struct {
+	mc     Machine
+	pos    [3]float64
+	blocks []*plannerBlock
+	dwell  float64 // pause before the next block
+}
+
+// line adds a straight move to target, at the given feed rate (mm/min), or as
+// fast as possible if feed is 0.
+func (pl *planner) line(target [3]float64, feed float64, pass int) {
+	delta := [3]float64{}
+	length := 0.0
+	for i := range delta {
+		delta[i] = target[i] - pl.pos[i]
+		length += delta[i] * delta[i]
+	}
+	length = math.Sqrt(length)
+	if length < TOLERANCE {
+		return
+	}
+
+	b := &plannerBlock{
+		pass:    pass,
+		length:  length,
+		nominal: math.Inf(1),
+		accel:   math.Inf(1),
+		dwell:   pl.dwell,
+	}
+	if feed > 0 {
+		b.nominal = feed / 60
+	}
+	for i := range delta {
+		b.unit[i] = delta[i] / length
+		if u := math.Abs(b.unit[i]); u > 0 {
+			b.nominal = math.Min(b.nominal, pl.mc.MaxRate[i]/60/u)
+			b.accel = math.Min(b.accel, pl.mc.Accel[i]/u)
+		}
+	}
+
+	// junction speed, see grbl's planner
+	if n := len(pl.blocks); n > 0 && b.dwell == 0 {
+		prev := pl.blocks[n-1]
+		cos := 0.0
+		for i := range b.unit {
+			cos -= prev.unit[i] * b.unit[i]
+		}
+		switch {
+		case cos > 0.999999:
+			// going back, full stop
+			b.entryMax = 0
+		case cos < -0.999999:
+			// straight line
+			b.entryMax = math.Inf(1)
+		default:
+			sin := math.Sqrt(0.5 * (1 - cos))
+			a := math.Min(prev.accel, b.accel)
+			b.entryMax = math.Sqrt(a * pl.mc.JunctionDeviation * sin / (1 - sin))
+		}
+		b.entryMax = math.Min(b.entryMax, math.Min(prev.nominal, b.nominal))
+	}
+
+	pl.blocks = append(pl.blocks, b)
+	pl.pos = target
+	pl.dwell = 0
+}
+
+// arc adds an arc at depth z, split in segments within the arc tolerance. The
+// height changes linearly along helical arcs.
+func (pl *planner) arc(a *Arc, z, feed float64, pass int) {
+	r := a.radius()
+	sweep := math.Abs(a.Sweep())
+	tol := pl.mc.ArcTolerance
+	n := 1
+	if tol > 0 && tol < r {
+		n = int(math.Floor(0.5 * sweep * r / math.Sqrt(tol*(2*r-tol))))
+	}
+	for i := 1; i < n; i++ {
+		t := float64(i) / float64(n)
+		p := a.at(sweep * t)
+		pl.line([3]float64{p.X, p.Y, z + a.From.Z + t*(a.To.Z-a.From.Z)}, feed, pass)
+	}
+	pl.line([3]float64{a.To.X, a.To.Y, z + a.To.Z}, feed, pass)
+}
+
+// plan computes the speed at the start of each block
+func (pl *planner) plan() {
+	bs := pl.blocks
+	// backward pass: make sure the machine can stop in time
+	next := 0.0
+	for i := len(bs) - 1; i >= 0; i-- {
+		b := bs[i]
+		b.entry = math.Min(b.entryMax, math.Sqrt(next*next+2*b.accel*b.length))
+		next = b.entry
+	}
+	// forward pass: make sure the machine can accelerate in time
+	for i := 0; i+1 < len(bs); i++ {
+		b := bs[i]
+		exit := math.Sqrt(b.entry*b.entry + 2*b.accel*b.length)
+		if bs[i+1].entry > exit {
+			bs[i+1].entry = exit
+		}
+	}
+}
+
+// duration returns the time needed to run a block with a trapezoidal speed
+// profile, in seconds.
+func (b *plannerBlock) duration(exit float64) float64 {
+	v0, v1, v, a, l := b.entry, exit, b.nominal, b.accel, b.length
+	da := (v*v - v0*v0) / (2 * a)
+	dd := (v*v - v1*v1) / (2 * a)
+	if da+dd <= l {
+		return (v-v0)/a + (v-v1)/a + (l-da-dd)/v
+	}
+	// triangular profile, the nominal speed is never reached
+	peak := math.Sqrt((2*a*l + v0*v0 + v1*v1) / 2)
+	return (peak-v0)/a + (peak-v1)/a
+}
+
+// Estimate returns the time needed to run the toolpath. The machine is
+// supposed to start at the origin, at safe height.
+func (mc Machine) Estimate(tp Toolpath) Estimate {
+	pl := &planner{mc: mc, pos: [3]float64{0, 0, tp.SafeZ}}
+	var pos Vector
+	down := false
+	for i, p := range tp.Passes {
+		start, end := p.Path.Move()
+		start, end = p.actual(start), p.actual(end)
+		z := -p.Depth
+		feed := p.Feed
+		if feed <= 0 {
+			feed = mc.Feed
+		}
+		if !down || !pos.near(start) {
+			pl.line([3]float64{pl.pos[0], pl.pos[1], tp.SafeZ}, 0, i)
+			pl.line([3]float64{start.X, start.Y, tp.SafeZ}, 0, i)
+		}
+		pl.dwell += p.Dwell
+		plunge := p.plungeFeed()
+		if plunge <= 0 {
+			plunge = feed
+		}
+		pl.line([3]float64{start.X, start.Y, start.Z}, plunge, i)
+		for _, m := range p.Path {
+			switch m := m.(type) {
+			case *Arc:
+				pl.arc(m, z, feed, i)
+			default:
+				_, to := m.Move()
+				pl.line([3]float64{to.X, to.Y, z + to.Z}, feed, i)
+			}
+		}
+		pos, down = end, true
+	}
+	if len(tp.Passes) > 0 {
+		pl.line([3]float64{pl.pos[0], pl.pos[1], tp.SafeZ}, 0, len(tp.Passes)-1)
+	}
+	pl.plan()
+
+	seconds := make([]float64, len(tp.Passes))
+	for i, b := range pl.blocks {
+		exit := 0.0
+		if i+1 < len(pl.blocks) {
+			exit = pl.blocks[i+1].entry
+		}
+		seconds[b.pass] += b.dwell + b.duration(exit)
+	}
+	// dwells at the end of the toolpath
+	if len(tp.Passes) > 0 {
+		seconds[len(seconds)-1] += pl.dwell
+	}
+
+	e := Estimate{Passes: make([]time.Duration, len(seconds))}
+	for i, s := range seconds {
+		e.Passes[i] = time.Duration(s * float64(time.Second))
+		e.Total += e.Passes[i]
+	}
+	return e
+}
+
+// Comments returns the estimate as Gcode comments, to be put in the header of
+// the program.
+func (e Estimate) Comments(tp Toolpath) []gcode.Block {
+	line := func(format string, args ...interface{}) gcode.Block {
+		b := gcode.Block{}
+		b.AppendNode(&gcode.Comment{Content: fmt.Sprintf(format, args...)})
+		return b
+	}
+	bs := []gcode.Block{line("Estimated time: %s", e.Total.Round(time.Second))}
+	for i, d := range e.Passes {
+		p := tp.Passes[i]
+		bs = append(bs, line("Pass %d: %s at depth %g, %s", i, p.Operation, p.Depth, d.Round(time.Second)))
+	}
+	return bs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateLine(t *testing.T) {
+	mc := DefaultMachine()
+	tp := Toolpath{Passes: []Pass{{
+		Feed: 600,
+		Path: Path{&Line{Vector{0, 0, 0}, Vector{100, 0, 0}}},
+	}}}
+	e := mc.Estimate(tp)
+	// 10s at 10mm/s, plus 0.04s lost accelerating and decelerating
+	assert.InDelta(t, 10.04, e.Total.Seconds(), 1e-6)
+	assert.Equal(t, e.Total, e.Passes[0])
+}
+
+func TestEstimateCorners(t *testing.T) {
+	mc := DefaultMachine()
+	straight := Toolpath{Passes: []Pass{{
+		Feed: 3000,
+		Path: Path{&Line{Vector{0, 0, 0}, Vector{40, 0, 0}}},
+	}}}
+	square := Toolpath{Passes: []Pass{{Feed: 3000, Path: polygon(
+		Vector{0, 0, 0}, Vector{10, 0, 0}, Vector{10, 10, 0}, Vector{0, 10, 0},
+	)}}}
+	assert.True(t, mc.Estimate(square).Total > mc.Estimate(straight).Total,
+		"the machine should slow down in corners")
+
+	// a circle is not cut as fast as the straight line, but does not stop
+	// at each segment either
+	c := Toolpath{Passes: []Pass{{Feed: 3000, Path: circle(Vector{0, 0, 0}, 40/(2*3.14159265))}}}
+	d := mc.Estimate(c).Total
+	assert.True(t, d > mc.Estimate(straight).Total)
+	assert.True(t, d < mc.Estimate(square).Total)
+}
+
+func TestEstimateDwell(t *testing.T) {
+	mc := DefaultMachine()
+	tp := Toolpath{SafeZ: 5, Passes: []Pass{
+		{Depth: 1, Feed: 600, Path: Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}}},
+		{Depth: 1, Feed: 600, Dwell: 2, Path: Path{&Line{Vector{20, 0, 0}, Vector{30, 0, 0}}}},
+	}}
+	e := mc.Estimate(tp)
+	assert.Len(t, e.Passes, 2)
+	assert.True(t, e.Passes[1] > e.Passes[0]+2*time.Second-time.Millisecond)
+	assert.Equal(t, e.Total, e.Passes[0]+e.Passes[1])
+}
+
+func TestEstimateGcodeDwell(t *testing.T) {
+	src := "G21 G90\nG0 Z5\nG0 X0 Y0\nG1 Z-1 F600\nG1 X10\nG4 P2\nG1 X20\nG0 Z5\nM2\n"
+	in, err := ReadGcode(strings.NewReader(src))
+	assert.NoError(t, err)
+	passes := in.Toolpath.Passes
+	assert.Len(t, passes, 2, "the pause starts a new pass")
+	assert.Equal(t, 0.0, passes[0].Dwell)
+	assert.Equal(t, 2.0, passes[1].Dwell)
+
+	mc := DefaultMachine()
+	without := in.Toolpath
+	without.Passes = []Pass{passes[0], passes[1]}
+	without.Passes[1].Dwell = 0
+	// the machine also stops to pause
+	assert.InDelta(t, 2.04, (mc.Estimate(in.Toolpath).Total - mc.Estimate(without).Total).Seconds(), 1e-3)
+}
+
+func TestOperationDwell(t *testing.T) {
+	op := Operation{Kind: Drilling, Depth: 2, Dwell: 0.5}
+	passes, err := op.Passes(Model{Path{&Drill{At: Vector{1, 1, 0}}}, Path{&Drill{At: Vector{5, 1, 0}}}})
+	assert.NoError(t, err)
+	assert.Len(t, passes, 2)
+	e := DefaultMachine().Estimate(Toolpath{SafeZ: 5, Passes: passes})
+	assert.True(t, e.Total > time.Second, "both holes pause")
+}
@@ -0,0 +1,220 @@
+package main
+
+// This file contains the code to import Excellon drill files. Each hole becomes
+// a path made of a single Drill, and holes are sorted in one layer per tool.
+// Routed slots (G00/G01 in the body) are not supported.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "excellon",
+		Extensions: []string{".drl", ".xln", ".exc", ".ncd"},
+		Magic:      isExcellon,
+		New:        func() Importer { return NewExcellonImporter() },
+	})
+}
+
+// isExcellon returns true if the file starts with the header marker
+func isExcellon(head []byte) bool {
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		return line == "M48"
+	}
+	return false
+}
+
+type ExcellonImporter struct {
+	Stats
+	tools     map[int]float64 // diameter of each tool
+	tool      int             // selected tool
+	scale     float64         // millimetres per unit
+	intDigits int             // coordinate format, when there is no decimal point
+	decDigits int             //
+	leading   bool            // leading zeros kept (LZ), trailing zeros omitted
+	relative  bool            // incremental coordinates (G91)
+	pos       Vector          // last position
+	layers    map[string]*Model
+}
+
+func NewExcellonImporter() *ExcellonImporter {
+	return &ExcellonImporter{
+		tools:     map[int]float64{},
+		scale:     25.4,
+		intDigits: 2,
+		decDigits: 4,
+		layers:    map[string]*Model{},
+	}
+}
+
+func (im *ExcellonImporter) Import(stream io.Reader) (*Model, Stats, error) {
+	Log.Println("Importing holes")
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := strings.ToUpper(strings.TrimSpace(scanner.Text()))
+		if err := im.Line(line); err != nil {
+			return nil, im.Stats, fmt.Errorf("excellon: %s: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, im.Stats, err
+	}
+	return flatten(im.layers), im.Stats, nil
+}
+
+// Layers returns the holes, one layer per tool
+func (im *ExcellonImporter) Layers() map[string]*Model {
+	return im.layers
+}
+
+// Line interprets a single line of the file
+func (im *ExcellonImporter) Line(line string) error {
+	switch {
+	case line == "" || strings.HasPrefix(line, ";"):
+		// comment
+	case strings.HasPrefix(line, "METRIC"), strings.HasPrefix(line, "INCH"):
+		im.units(line)
+	case line == "M71":
+		im.scale = 1
+		im.intDigits, im.decDigits = 3, 3
+	case line == "M72":
+		im.scale = 25.4
+		im.intDigits, im.decDigits = 2, 4
+	case line == "G90":
+		im.relative = false
+	case line == "G91":
+		im.relative = true
+	case strings.HasPrefix(line, "T"):
+		return im.Tool(line)
+	case strings.HasPrefix(line, "X") || strings.HasPrefix(line, "Y"):
+		return im.Hole(line)
+	case strings.HasPrefix(line, "G00"), strings.HasPrefix(line, "G01"):
+		Log.Println("Ignored routing instruction", line)
+		im.Ignored++
+	default:
+		// header markers, drill mode, end of program...
+	}
+	return nil
+}
+
+// units handles METRIC,LZ,000.000 and the like
+func (im *ExcellonImporter) units(line string) {
+	fields := strings.Split(line, ",")
+	if fields[0] == "METRIC" {
+		im.scale = 1
+		im.intDigits, im.decDigits = 3, 3
+	} else {
+		im.scale = 25.4
+		im.intDigits, im.decDigits = 2, 4
+	}
+	for _, f := range fields[1:] {
+		switch {
+		case f == "LZ":
+			im.leading = true
+		case f == "TZ":
+			im.leading = false
+		case strings.Contains(f, "."):
+			parts := strings.Split(f, ".")
+			im.intDigits, im.decDigits = len(parts[0]), len(parts[1])
+		}
+	}
+}
+
+// Tool defines (T1C0.8) or selects (T1) a tool
+func (im *ExcellonImporter) Tool(line string) error {
+	i := 1
+	for i < len(line) && '0' <= line[i] && line[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(line[1:i])
+	if err != nil {
+		return err
+	}
+	if c := strings.Index(line, "C"); c >= 0 {
+		j := c + 1
+		for j < len(line) && (line[j] == '.' || ('0' <= line[j] && line[j] <= '9')) {
+			j++
+		}
+		d, err := strconv.ParseFloat(line[c+1:j], 64)
+		if err != nil {
+			return err
+		}
+		im.tools[n] = d * im.scale
+		return nil
+	}
+	im.tool = n
+	return nil
+}
+
+// Hole drills a hole with the selected tool, as in X1.5Y2.25
+func (im *ExcellonImporter) Hole(line string) error {
+	at := im.pos
+	if im.relative {
+		at = Vector{}
+	}
+	for _, axis := range []byte{'X', 'Y'} {
+		i := strings.IndexByte(line, axis)
+		if i < 0 {
+			continue
+		}
+		j := i + 1
+		for j < len(line) && !isLetter(line[j]) {
+			j++
+		}
+		v, err := im.coordinate(line[i+1 : j])
+		if err != nil {
+			return err
+		}
+		if axis == 'X' {
+			at.X = v
+		} else {
+			at.Y = v
+		}
+	}
+	if im.relative {
+		at = at.Sum(im.pos)
+	}
+	im.pos = at
+
+	name := fmt.Sprintf("T%d", im.tool)
+	m, ok := im.layers[name]
+	if !ok {
+		m = &Model{}
+		im.layers[name] = m
+	}
+	*m = append(*m, Path{&Drill{at, im.tools[im.tool]}})
+	im.Imported++
+	return nil
+}
+
+// coordinate converts a coordinate to millimetres
+func (im *ExcellonImporter) coordinate(s string) (float64, error) {
+	if strings.Contains(s, ".") {
+		v, err := strconv.ParseFloat(s, 64)
+		return v * im.scale, err
+	}
+	sign := 1.0
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+	if im.leading {
+		for len(s) < im.intDigits+im.decDigits {
+			s += "0"
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return sign * v / math.Pow10(im.decDigits) * im.scale, err
+}
@@ -0,0 +1,165 @@
+package main
+
+// This file contains the fillets added to the inside corners of closed paths.
+// A round tool leaves material in the corners it can not reach, so a part with
+// square corners does not fit in the slot; the fillets let the tool reach the
+// corner itself. Dogbones go along the bisector of the corner, T-bones extend
+// its longest side.
+
+import "math"
+
+// Kinds of fillets
+const (
+	Dogbone = "dogbone"
+	TBone   = "tbone"
+)
+
+// Fillets describes the fillets added to inside corners
+type Fillets struct {
+	Kind     string  // Dogbone or TBone, Dogbone if empty
+	Radius   float64 // radius of the tool
+	MaxAngle float64 // largest angle of the corners receiving a fillet, in degrees, 180 if 0
+}
+
+// corner returns the fillet between the moves a and b, or nil if the corner
+// does not need one. The material is on the left of the moves if left is set.
+func (f Fillets) corner(a, b Move, left bool) *Arc {
+	_, c := a.Move()
+	tin, tout := tangent(a, c), tangent(b, c)
+	turn := tin.X*tout.Y - tin.Y*tout.X
+	if left {
+		turn = -turn
+	}
+	if turn <= TOLERANCE {
+		// the tool is on the outside of the corner
+		return nil
+	}
+	max := f.MaxAngle
+	if max <= 0 {
+		max = 180
+	}
+	angle := math.Acos(math.Max(-1, math.Min(1, -tin.Dot(tout))))
+	if angle > deg2rad(max) {
+		return nil
+	}
+
+	// normal returns the side of the tool, for a direction of travel
+	normal := func(t Vector) Vector {
+		if left {
+			return Vector{t.Y, -t.X, 0}
+		}
+		return Vector{-t.Y, t.X, 0}
+	}
+	// the fillet starts and ends where the circle crosses the moves, or at the
+	// corner if the circle is tangent to the move. The fillet goes around the
+	// side of the circle away from the tool, through deep.
+	var dir, away Vector
+	var from, to *Vector
+	switch {
+	case f.Kind != TBone:
+		dir = tout.Diff(tin)
+		dir = dir.Divide(dir.Norm())
+		away = dir.Multiply(-1)
+	case measure(a).Length() >= measure(b).Length():
+		dir, away = normal(tin), tin
+		from = &c
+	default:
+		dir, away = normal(tout), tout.Multiply(-1)
+		to = &c
+	}
+	center := c.Sum(dir.Multiply(f.Radius))
+	center.Z = 0
+	deep := center.Sum(away.Multiply(f.Radius))
+
+	for _, arc := range circle(center, f.Radius) {
+		for _, p := range intersect(a, arc) {
+			if !p.near(c) && (from == nil || position(a, p) > position(a, *from)) {
+				p := p
+				from = &p
+			}
+		}
+		for _, p := range intersect(b, arc) {
+			if !p.near(c) && (to == nil || position(b, p) < position(b, *to)) {
+				p := p
+				to = &p
+			}
+		}
+	}
+	if from == nil || to == nil {
+		Log.Println("No room for a fillet at", c)
+		return nil
+	}
+	fillet := &Arc{*from, *to, center, false}
+	if !fillet.covers(deep) {
+		fillet.CW = true
+	}
+	return fillet
+}
+
+// trim returns the part of a line or an arc between two of its points
+func trim(m Move, from, to Vector) Move {
+	if a, ok := m.(*Arc); ok {
+		return &Arc{from, to, a.Center, a.CW}
+	}
+	return &Line{from, to}
+}
+
+// Path returns a copy of a closed path with fillets at its inside corners.
+// The material is on the left of the path if left is set.
+func (f Fillets) Path(p Path, left bool) Path {
+	n := len(p)
+	// fillets[i] is at the start of p[i]
+	fillets := make([]*Arc, n)
+	for i := range p {
+		fillets[i] = f.corner(p[(i+n-1)%n], p[i], left)
+	}
+	// a move too short for the fillets at both ends only keeps the first one
+	for i, m := range p {
+		start, end := fillets[i], fillets[(i+1)%n]
+		if start != nil && end != nil && position(m, start.To) >= position(m, end.From)-TOLERANCE {
+			fillets[(i+1)%n] = nil
+		}
+	}
+
+	res := Path{}
+	for i, m := range p {
+		from, to := m.Move()
+		if fillet := fillets[i]; fillet != nil {
+			res = append(res, fillet)
+			from = fillet.To
+		}
+		if fillet := fillets[(i+1)%n]; fillet != nil {
+			to = fillet.From
+		}
+		if fillets[i] != nil || fillets[(i+1)%n] != nil {
+			res = append(res, trim(m, from, to))
+		} else {
+			res = append(res, clone(m))
+		}
+	}
+	return res
+}
+
+// Apply adds fillets to the closed paths of the model whose indexes are
+// accepted by selected, or to all of them if selected is nil. Whether a path
+// is an outline or a hole is found from the paths around it.
+func (f Fillets) Apply(m Model, selected func(int) bool) Model {
+	indexes := []int{}
+	closed := []Path{}
+	for i, p := range m {
+		if p.IsClosed() && math.Abs(p.Area()) > TOLERANCE {
+			indexes = append(indexes, i)
+			closed = append(closed, p)
+		}
+	}
+	depths := nesting(closed)
+	res := append(Model{}, m...)
+	for k, i := range indexes {
+		if selected != nil && !selected(i) {
+			continue
+		}
+		left := (m[i].Area() > 0) == (depths[k]%2 == 0)
+		res[i] = f.Path(m[i], left)
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// notched returns a square part with a slot of the given width, 6 deep,
+// opening on its top side
+func notched(width float64) Path {
+	l, r := 5-width/2, 5+width/2
+	return polygon(Vector{0, 0, 0}, Vector{10, 0, 0}, Vector{10, 10, 0}, Vector{r, 10, 0},
+		Vector{r, 4, 0}, Vector{l, 4, 0}, Vector{l, 10, 0}, Vector{0, 10, 0})
+}
+
+// assertContinuous checks that each move starts where the previous one ends
+func assertContinuous(t *testing.T, p Path) {
+	for i, m := range p {
+		_, to := m.Move()
+		from, _ := p[(i+1)%len(p)].Move()
+		assert.True(t, to.near(from), "gap after move %d: %v %v", i, to, from)
+	}
+}
+
+func arcs(p Path) []*Arc {
+	res := []*Arc{}
+	for _, m := range p {
+		if a, ok := m.(*Arc); ok {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func TestDogbone(t *testing.T) {
+	f := Fillets{Kind: Dogbone, Radius: 1}
+	m := f.Apply(Model{notched(4)}, nil)
+	p := m[0]
+	assert.Len(t, p, 10)
+	assertContinuous(t, p)
+
+	fillets := arcs(p)
+	assert.Len(t, fillets, 2)
+	for i, c := range []Vector{{7, 4, 0}, {3, 4, 0}} {
+		a := fillets[i]
+		assert.InDelta(t, 1, a.radius(), 1e-9)
+		assert.InDelta(t, 1, c.Diff(a.Center).Norm(), 1e-9, "the fillet goes through the corner")
+		assert.True(t, a.covers(c))
+		// the center is on the bisector, inside the slot
+		assert.InDelta(t, 4+math.Sqrt(0.5), a.Center.Y, 1e-9)
+	}
+	// the area of the part shrinks
+	assert.True(t, p.Area() < notched(4).Area())
+}
+
+func TestTBone(t *testing.T) {
+	f := Fillets{Kind: TBone, Radius: 1}
+	p := f.Apply(Model{notched(5)}, nil)[0]
+	assertContinuous(t, p)
+	fillets := arcs(p)
+	assert.Len(t, fillets, 2)
+	// the notches extend the sides of the slot, centered on its bottom
+	assertNear(t, Vector{6.5, 4, 0}, fillets[0].Center)
+	assertNear(t, Vector{3.5, 4, 0}, fillets[1].Center)
+	assertNear(t, Vector{5.5, 4, 0}, fillets[0].To)
+	assert.True(t, fillets[0].covers(Vector{6.5, 3, 0}), "the fillet goes below the slot")
+	assert.True(t, fillets[1].covers(Vector{3.5, 3, 0}))
+	assert.True(t, p.Area() < notched(5).Area())
+}
+
+func TestFilletsHole(t *testing.T) {
+	f := Fillets{Radius: 0.5}
+	hole := square(3, 3, 4)
+	m := f.Apply(Model{square(0, 0, 10), hole}, nil)
+	assert.Len(t, m[0], 4, "the outline has no inside corner")
+	assert.Len(t, arcs(m[1]), 4, "all the corners of the hole are inside corners")
+	assertContinuous(t, m[1])
+
+	// the direction of the paths does not matter
+	hole = hole.Clone()
+	hole.Reverse()
+	m = f.Apply(Model{square(0, 0, 10), hole}, nil)
+	assert.Len(t, arcs(m[1]), 4)
+
+	// square corners are sharper than the threshold
+	f.MaxAngle = 80
+	m = f.Apply(Model{square(0, 0, 10), hole}, nil)
+	assert.Len(t, arcs(m[1]), 0)
+}
+
+func TestFilletsSelected(t *testing.T) {
+	f := Fillets{Radius: 0.5}
+	part, slot := notched(4), square(20, 3, 4)
+	m := f.Apply(Model{part, slot}, func(i int) bool { return i == 0 })
+	assert.Len(t, arcs(m[0]), 2)
+	assert.Equal(t, slot, m[1], "paths not selected are left alone")
+	assert.Len(t, part, 8, "the model is not modified")
+}
@@ -0,0 +1,174 @@
+package main
+
+// This file contains the diagnostic of open paths, and the repair of the small
+// gaps that leave profiles open: an open profile is cut along the path, with
+// no offset.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Endpoint is an end of an open path
+type Endpoint struct {
+	Path int  // index of the path in the model
+	End  bool // the end of the path, rather than its start
+	At   Vector
+}
+
+func (e Endpoint) String() string {
+	if e.End {
+		return fmt.Sprintf("end of path %d %v", e.Path, e.At)
+	}
+	return fmt.Sprintf("start of path %d %v", e.Path, e.At)
+}
+
+// Opening describes an open path, with the endpoints nearest to its ends
+type Opening struct {
+	Path     int
+	From, To Vector
+	NearFrom *Endpoint // nil if there is no other endpoint
+	NearTo   *Endpoint
+}
+
+func (o Opening) String() string {
+	near := func(p Vector, e *Endpoint) string {
+		if e == nil {
+			return "nothing"
+		}
+		return fmt.Sprintf("%v, %.3g away", *e, e.At.Diff(p).Norm())
+	}
+	return fmt.Sprintf("path %d open from %v to %v, nearest to its start: %s, nearest to its end: %s",
+		o.Path, o.From, o.To, near(o.From, o.NearFrom), near(o.To, o.NearTo))
+}
+
+// endpoints returns the ends of the open paths of the model. Drills have no
+// ends.
+func (m Model) endpoints() []Endpoint {
+	ends := []Endpoint{}
+	for i, p := range m {
+		if len(p) == 0 || p.IsClosed() {
+			continue
+		}
+		if _, ok := p[0].(*Drill); ok {
+			continue
+		}
+		from, to := p.Move()
+		ends = append(ends, Endpoint{i, false, from}, Endpoint{i, true, to})
+	}
+	return ends
+}
+
+// nearest returns the endpoint closest to e, or nil
+func nearest(e Endpoint, ends []Endpoint) *Endpoint {
+	var best *Endpoint
+	for i, o := range ends {
+		if o.Path == e.Path && o.End == e.End {
+			continue
+		}
+		if best == nil || o.At.Diff(e.At).Norm() < best.At.Diff(e.At).Norm() {
+			best = &ends[i]
+		}
+	}
+	return best
+}
+
+// Openings returns the open paths of the model
+func (m Model) Openings() []Opening {
+	ends := m.endpoints()
+	res := []Opening{}
+	for i := 0; i < len(ends); i += 2 {
+		from, to := ends[i], ends[i+1]
+		res = append(res, Opening{
+			Path:     from.Path,
+			From:     from.At,
+			To:       to.At,
+			NearFrom: nearest(from, ends),
+			NearTo:   nearest(to, ends),
+		})
+	}
+	return res
+}
+
+// bridge joins the end of a to the start of b, both being in the same path
+// if b is nil. When the moves on both sides of the gap are lines, they are
+// extended or trimmed to the point where they cross, if it is within
+// threshold; otherwise a line is inserted.
+func bridge(a, b Path, threshold float64) Path {
+	first := a
+	if b != nil {
+		first = b
+	}
+	la, ok1 := a[len(a)-1].(*Line)
+	lb, ok2 := first[0].(*Line)
+	if ok1 && ok2 && la != lb {
+		da, db := la.To.Diff(la.From), lb.To.Diff(lb.From)
+		if den := da.X*db.Y - da.Y*db.X; math.Abs(den) > TOLERANCE*da.Norm()*db.Norm() {
+			v := lb.From.Diff(la.From)
+			x := la.From.Sum(da.Multiply((v.X*db.Y - v.Y*db.X) / den))
+			x.Z = la.To.Z
+			if x.Diff(la.To).Norm() <= threshold && x.Diff(lb.From).Norm() <= threshold &&
+				x.Diff(la.From).Dot(da) > 0 && lb.To.Diff(x).Dot(db) > 0 {
+				la.To, lb.From = x, x
+				return append(a, b...)
+			}
+		}
+	}
+	_, from := a.Move()
+	to, _ := first.Move()
+	if from != to {
+		a = append(a, &Line{from, to})
+	}
+	return append(a, b...)
+}
+
+// CloseGaps returns a copy of the model where the ends of open paths closer
+// than threshold are joined, nearest first, and the number of gaps closed.
+// Paths left as they are are not copied.
+func (m Model) CloseGaps(threshold float64) (Model, int) {
+	res := append(Model{}, m...)
+	count := 0
+	for {
+		ends := res.endpoints()
+		type pair struct {
+			a, b Endpoint
+			dist float64
+		}
+		pairs := []pair{}
+		for i, a := range ends {
+			for _, b := range ends[i+1:] {
+				d := a.At.Diff(b.At).Norm()
+				if d > threshold || (a.Path == b.Path && len(res[a.Path]) < 2) {
+					continue
+				}
+				pairs = append(pairs, pair{a, b, d})
+			}
+		}
+		if len(pairs) == 0 {
+			return res, count
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i].dist < pairs[j].dist
+		})
+		a, b := pairs[0].a, pairs[0].b
+
+		p := res[a.Path].Clone()
+		if a.Path == b.Path {
+			// the path closes on itself
+			res[a.Path] = bridge(p, nil, threshold)
+		} else {
+			// the end of a is joined to the start of b
+			q := res[b.Path].Clone()
+			if !a.End {
+				p.Reverse()
+			}
+			if b.End {
+				q.Reverse()
+			}
+			res[a.Path] = bridge(p, q, threshold)
+			res = append(res[:b.Path], res[b.Path+1:]...)
+		}
+		count++
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenings(t *testing.T) {
+	m := Model{
+		square(20, 20, 1),
+		Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}, &Line{Vector{10, 0, 0}, Vector{10, 10, 0}}},
+		Path{&Line{Vector{10.05, 10, 0}, Vector{0, 10, 0}}},
+	}
+	open := m.Openings()
+	assert.Len(t, open, 2, "closed paths are not reported")
+	assert.Equal(t, 1, open[0].Path)
+	assert.Equal(t, Vector{0, 0, 0}, open[0].From)
+	assert.Equal(t, Endpoint{2, true, Vector{0, 10, 0}}, *open[0].NearFrom)
+	assert.Equal(t, Endpoint{2, false, Vector{10.05, 10, 0}}, *open[0].NearTo)
+	assert.Equal(t, Endpoint{1, true, Vector{10, 10, 0}}, *open[1].NearFrom)
+	assert.True(t, strings.Contains(open[0].String(), "start of path 2"))
+	assert.True(t, strings.Contains(open[0].String(), "0.05 away"))
+}
+
+func TestCloseGapsTrim(t *testing.T) {
+	// the lines overshoot each other, they are trimmed where they cross
+	m := Model{
+		Path{&Line{Vector{0, 0, 0}, Vector{10.02, 0, 0}}},
+		Path{&Line{Vector{10, -0.03, 0}, Vector{10, 10, 0}}},
+	}
+	res, n := m.CloseGaps(0.1)
+	assert.Equal(t, 1, n)
+	assert.Len(t, res, 1)
+	assert.Len(t, res[0], 2)
+	assertNear(t, Vector{10, 0, 0}, res[0][0].(*Line).To)
+	assertNear(t, Vector{10, 0, 0}, res[0][1].(*Line).From)
+	assert.Equal(t, Vector{10.02, 0, 0}, m[0][0].(*Line).To, "the model is not modified")
+}
+
+func TestCloseGapsClose(t *testing.T) {
+	// an arc does not meet the start of the line: a line is inserted
+	m := Model{
+		Path{
+			&Line{Vector{0, 0, 0}, Vector{2, 0, 0}},
+			&Arc{Vector{2, 0, 0}, Vector{0.04, 0, 0}, Vector{1.02, 0, 0}, false},
+		},
+		Path{&Line{Vector{5, 0, 0}, Vector{6, 0, 0}}},
+	}
+	res, n := m.CloseGaps(0.05)
+	assert.Equal(t, 1, n)
+	assert.Len(t, res, 2)
+	assert.True(t, res[0].IsClosed())
+	assert.Equal(t, &Line{Vector{0.04, 0, 0}, Vector{0, 0, 0}}, res[0][2])
+	assert.Len(t, res[0], 3)
+	assert.Len(t, res.Openings(), 1)
+
+	// nothing under the threshold
+	res, n = m.CloseGaps(0.01)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, m, res)
+}
+
+func TestCloseGapsReversed(t *testing.T) {
+	// paths meeting start to start are joined end to start
+	m := Model{
+		Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}},
+		Path{&Line{Vector{0, 0.01, 0}, Vector{0, 10, 0}}},
+	}
+	res, n := m.CloseGaps(0.1)
+	assert.Equal(t, 1, n)
+	assert.Len(t, res, 1)
+	from, to := res[0].Move()
+	assert.Equal(t, Vector{10, 0, 0}, from)
+	assert.Equal(t, Vector{0, 10, 0}, to)
+	assertContinuous(t, append(res[0], &Line{to, from}))
+}
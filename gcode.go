@@ -30,6 +30,27 @@ func xy(v Vector) []gcode.Node {
 	}
 }
 
+func xyz(v Vector) []gcode.Node {
+	return append(xy(v), word('Z', v.Z))
+}
+
+// height removes the Z word of the block if it gives the height z, the tool
+// being already there. It returns the block, and the height after it.
+func height(b gcode.Block, z float64) (gcode.Block, float64) {
+	nodes := []gcode.Node{}
+	for _, n := range b.Nodes {
+		if w, ok := n.(*gcode.Word); ok && w.Address == 'Z' {
+			if w.Command == z {
+				continue
+			}
+			z = w.Command
+		}
+		nodes = append(nodes, n)
+	}
+	b.Nodes = nodes
+	return b, z
+}
+
 func ij(v Vector) []gcode.Node {
 	return []gcode.Node{
 		word('I', v.X),
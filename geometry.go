@@ -0,0 +1,256 @@
+package main
+
+// This file contains geometric helpers on moves, used by the intersection,
+// boolean and offset code.
+
+import (
+	"fmt"
+	"math"
+)
+
+// TOLERANCE is the distance under which two points are considered the same by
+// the geometric algorithms. It is much smaller than EPSILON, which deals with
+// the precision of imported drawings.
+const TOLERANCE float64 = 1e-7
+
+// cross returns the z component of the cross product of v and o
+func (v Vector) cross(o Vector) float64 {
+	return v.X*o.Y - v.Y*o.X
+}
+
+// near returns true if v and o are closer than TOLERANCE
+func (v Vector) near(o Vector) bool {
+	return v.Diff(o).Norm() <= TOLERANCE
+}
+
+// normalizeAngle brings an angle back to [0, 2π)
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 2*math.Pi)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle
+}
+
+func (a Arc) radius() float64 {
+	return a.From.Diff(a.Center).Norm()
+}
+
+func (a Arc) startAngle() float64 {
+	return vec2angle(a.From.Diff(a.Center))
+}
+
+// Sweep returns the angle covered by the arc, in radians, positive when CCW
+// and negative when CW. An arc ending where it starts is a full circle.
+func (a Arc) Sweep() float64 {
+	end := vec2angle(a.To.Diff(a.Center))
+	s := normalizeAngle(end - a.startAngle())
+	if a.CW {
+		s = normalizeAngle(a.startAngle() - end)
+	}
+	if s < TOLERANCE/math.Max(a.radius(), TOLERANCE) {
+		s = 2 * math.Pi
+	}
+	if a.CW {
+		return -s
+	}
+	return s
+}
+
+// offsetAngle returns the (positive) angle between the start of the arc and
+// p, in the direction of travel of the arc.
+func (a Arc) offsetAngle(p Vector) float64 {
+	angle := vec2angle(p.Diff(a.Center))
+	if a.CW {
+		return normalizeAngle(a.startAngle() - angle)
+	}
+	return normalizeAngle(angle - a.startAngle())
+}
+
+// at returns the point of the arc at the given offset angle from its start
+func (a Arc) at(offset float64) Vector {
+	angle := offset
+	if a.CW {
+		angle = -angle
+	}
+	p := pol2car(a.startAngle()+angle, a.radius()).Sum(a.Center)
+	// the height of helical arcs changes linearly
+	p.Z = a.From.Z
+	if sweep := math.Abs(a.Sweep()); sweep > 0 {
+		p.Z += (a.To.Z - a.From.Z) * offset / sweep
+	}
+	return p
+}
+
+// covers returns true if the direction of p, seen from the center, lies within
+// the angular span of the arc.
+func (a Arc) covers(p Vector) bool {
+	if p.near(a.From) || p.near(a.To) {
+		return true
+	}
+	tol := TOLERANCE / math.Max(a.radius(), TOLERANCE)
+	offset := a.offsetAngle(p)
+	return offset <= math.Abs(a.Sweep())+tol || offset >= 2*math.Pi-tol
+}
+
+// tangent returns the unit vector tangent to the move at p, in the direction
+// of travel.
+func tangent(m Move, p Vector) Vector {
+	switch m := m.(type) {
+	case *Arc:
+		r := p.Diff(m.Center)
+		r = r.Divide(r.Norm())
+		if m.CW {
+			return Vector{r.Y, -r.X, 0}
+		}
+		return Vector{-r.Y, r.X, 0}
+	default:
+		from, to := m.Move()
+		d := to.Diff(from)
+		return d.Divide(d.Norm())
+	}
+}
+
+// midpoint returns the point halfway along the move
+func midpoint(m Move) Vector {
+	if a, ok := m.(*Arc); ok {
+		return a.at(math.Abs(a.Sweep()) / 2)
+	}
+	from, to := m.Move()
+	return from.Sum(to).Divide(2)
+}
+
+// distance returns the distance between p and the move
+func distance(m Move, p Vector) float64 {
+	switch m := m.(type) {
+	case *Arc:
+		if m.covers(p) {
+			return math.Abs(p.Diff(m.Center).Norm() - m.radius())
+		}
+		return math.Min(p.Diff(m.From).Norm(), p.Diff(m.To).Norm())
+	default:
+		from, to := m.Move()
+		d := to.Diff(from)
+		l := d.Dot(d)
+		if l == 0 {
+			return p.Diff(from).Norm()
+		}
+		t := math.Max(0, math.Min(1, p.Diff(from).Dot(d)/l))
+		return p.Diff(from.Sum(d.Multiply(t))).Norm()
+	}
+}
+
+// Box is an axis aligned bounding box
+type Box struct {
+	Min, Max Vector
+}
+
+// emptyBox returns a box containing nothing, which extend and union grow from
+func emptyBox() Box {
+	inf := math.Inf(1)
+	return Box{Vector{inf, inf, inf}, Vector{-inf, -inf, -inf}}
+}
+
+// Empty returns true if the box contains nothing
+func (b Box) Empty() bool {
+	return b.Min.X > b.Max.X
+}
+
+func (b Box) String() string {
+	return fmt.Sprintf("X %g to %g, Y %g to %g, Z %g to %g",
+		b.Min.X, b.Max.X, b.Min.Y, b.Max.Y, b.Min.Z, b.Max.Z)
+}
+
+// overlaps returns true if the boxes overlap in the XY plane
+func (b Box) overlaps(o Box) bool {
+	return b.Min.X <= o.Max.X+TOLERANCE && o.Min.X <= b.Max.X+TOLERANCE &&
+		b.Min.Y <= o.Max.Y+TOLERANCE && o.Min.Y <= b.Max.Y+TOLERANCE
+}
+
+func (b Box) extend(v Vector) Box {
+	return b.union(Box{v, v})
+}
+
+func (b Box) union(o Box) Box {
+	return Box{
+		Vector{math.Min(b.Min.X, o.Min.X), math.Min(b.Min.Y, o.Min.Y), math.Min(b.Min.Z, o.Min.Z)},
+		Vector{math.Max(b.Max.X, o.Max.X), math.Max(b.Max.Y, o.Max.Y), math.Max(b.Max.Z, o.Max.Z)},
+	}
+}
+
+// bounds returns the bounding box of a move, or of its ends if it has no
+// Bounds method
+func bounds(m Move) Box {
+	if b, ok := m.(interface{ Bounds() Box }); ok {
+		return b.Bounds()
+	}
+	from, to := m.Move()
+	return Box{from, from}.extend(to)
+}
+
+// winding returns the winding number of the closed paths around p. The
+// result is meaningless if p lies on one of the paths.
+func winding(paths []Path, p Vector) int {
+	w := 0
+	for _, path := range paths {
+		for _, m := range path {
+			for _, e := range monotone(m) {
+				w += crossing(e, p)
+			}
+		}
+	}
+	return w
+}
+
+// monotone splits a move into parts that are monotone in Y
+func monotone(m Move) []Move {
+	a, ok := m.(*Arc)
+	if !ok {
+		return []Move{m}
+	}
+	r := a.radius()
+	extremes := []Vector{}
+	for _, p := range []Vector{a.Center.Sum(Vector{0, r, 0}), a.Center.Sum(Vector{0, -r, 0})} {
+		if a.covers(p) {
+			extremes = append(extremes, p)
+		}
+	}
+	parts := []Move{}
+	from := a.From
+	for _, p := range splitPoints(a, extremes) {
+		parts = append(parts, &Arc{from, p, a.Center, a.CW})
+		from = p
+	}
+	return append(parts, &Arc{from, a.To, a.Center, a.CW})
+}
+
+// crossing returns the contribution of a Y-monotone move to the winding number
+// around p: +1 if it crosses the half-line going right from p upward, -1 if it
+// crosses it downward, 0 otherwise.
+func crossing(m Move, p Vector) int {
+	from, to := m.Move()
+	up := from.Y <= p.Y && p.Y < to.Y
+	down := to.Y <= p.Y && p.Y < from.Y
+	if !up && !down {
+		return 0
+	}
+	var x float64
+	if a, ok := m.(*Arc); ok {
+		r := a.radius()
+		dy := p.Y - a.Center.Y
+		dx := math.Sqrt(math.Max(0, r*r-dy*dy))
+		if midpoint(a).X < a.Center.X {
+			dx = -dx
+		}
+		x = a.Center.X + dx
+	} else {
+		x = from.X + (p.Y-from.Y)/(to.Y-from.Y)*(to.X-from.X)
+	}
+	if x <= p.X {
+		return 0
+	}
+	if up {
+		return 1
+	}
+	return -1
+}
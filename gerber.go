@@ -0,0 +1,498 @@
+package main
+
+// This file contains the code to import RS-274X Gerber files, as produced by
+// PCB design tools. Flashes, draws and regions are converted to closed paths,
+// which are then merged into the outline of the copper. Isolation toolpaths are
+// obtained by offsetting this outline by the radius of the tool.
+//
+// Not supported: aperture macros, step and repeat, and clear polarity.
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "gerber",
+		Extensions: []string{".gbr", ".ger", ".gtl", ".gbl", ".gts", ".gbs", ".gko", ".gm1", ".art"},
+		Magic:      isGerber,
+		New:        func() Importer { return NewGerberImporter() },
+	})
+}
+
+// isGerber returns true if the header contains the extended commands found at
+// the beginning of Gerber files.
+func isGerber(head []byte) bool {
+	s := string(head)
+	return strings.Contains(s, "%FS") || strings.Contains(s, "%MO")
+}
+
+// aperture is a shape used to flash or draw
+type aperture struct {
+	shape  byte      // C, R, O or P
+	params []float64 // in millimetres, except for counts and angles
+}
+
+type GerberImporter struct {
+	Stats
+	shapes    [][]Path // copper features
+	apertures map[int]aperture
+	current   int     // selected aperture
+	intDigits int     // coordinate format
+	decDigits int     //
+	trailing  bool    // trailing zeros omitted
+	scale     float64 // millimetres per unit
+	interp    int     // 1: linear, 2: CW arc, 3: CCW arc
+	quadrant  bool    // single quadrant mode (G74)
+	dark      bool    // polarity
+	region    bool    // region mode (G36)
+	contour   Path    // contour of the region being built
+	op        int     // last D01, D02 or D03
+	pos       Vector  // current point
+	copper    *Model  // result
+}
+
+func NewGerberImporter() *GerberImporter {
+	return &GerberImporter{
+		apertures: map[int]aperture{},
+		intDigits: 2,
+		decDigits: 4,
+		scale:     1,
+		interp:    1,
+		dark:      true,
+		op:        2,
+	}
+}
+
+func (im *GerberImporter) Import(stream io.Reader) (*Model, Stats, error) {
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, im.Stats, err
+	}
+
+	Log.Println("Importing statements")
+	// extended commands are enclosed in %, all statements end with *
+	for i, block := range strings.Split(string(data), "%") {
+		extended := i%2 == 1
+		if extended && strings.HasPrefix(strings.TrimSpace(block), "AM") {
+			Log.Println("Ignored aperture macro")
+			im.Ignored++
+			continue
+		}
+		for _, st := range strings.Split(block, "*") {
+			st = strings.Join(strings.Fields(st), "")
+			if st == "" {
+				continue
+			}
+			if extended {
+				err = im.Extended(st)
+			} else {
+				err = im.Statement(st)
+			}
+			if err != nil {
+				return nil, im.Stats, fmt.Errorf("gerber: %s: %v", st, err)
+			}
+		}
+	}
+
+	Log.Println("Merging copper features")
+	m := Model(unionAll(im.shapes))
+	im.copper = &m
+	return im.copper, im.Stats, nil
+}
+
+// Layers returns the outline of the copper
+func (im *GerberImporter) Layers() map[string]*Model {
+	return map[string]*Model{"copper": im.copper}
+}
+
+// Extended executes an extended command (found between %)
+func (im *GerberImporter) Extended(st string) error {
+	switch {
+	case strings.HasPrefix(st, "FS"):
+		// FSLAX24Y24: zero omission, absolute notation, digits of X and Y
+		if len(st) < 10 {
+			return fmt.Errorf("malformed format specification")
+		}
+		im.trailing = st[2] == 'T'
+		xi := strings.Index(st, "X")
+		if xi < 0 || xi+2 >= len(st) {
+			return fmt.Errorf("malformed format specification")
+		}
+		im.intDigits = int(st[xi+1] - '0')
+		im.decDigits = int(st[xi+2] - '0')
+	case st == "MOMM":
+		im.scale = 1
+	case st == "MOIN":
+		im.scale = 25.4
+	case strings.HasPrefix(st, "AD"):
+		return im.Aperture(st[2:])
+	case st == "LPD":
+		im.dark = true
+	case st == "LPC":
+		im.dark = false
+	default:
+		// attributes, image settings and the like don't change the geometry
+	}
+	return nil
+}
+
+// Aperture defines an aperture, as in D10C,0.5
+func (im *GerberImporter) Aperture(def string) error {
+	if !strings.HasPrefix(def, "D") {
+		return fmt.Errorf("malformed aperture")
+	}
+	def = def[1:]
+	i := 0
+	for i < len(def) && '0' <= def[i] && def[i] <= '9' {
+		i++
+	}
+	code, err := strconv.Atoi(def[:i])
+	if err != nil || i == len(def) {
+		return fmt.Errorf("malformed aperture")
+	}
+	shape := def[i]
+	if !strings.ContainsRune("CROP", rune(shape)) || (i+1 < len(def) && def[i+1] != ',') {
+		Log.Printf("Ignored aperture D%d with template %s\n", code, def[i:])
+		im.Ignored++
+		return nil
+	}
+	params := []float64{}
+	if i+2 < len(def) {
+		for _, s := range strings.Split(def[i+2:], "X") {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return err
+			}
+			params = append(params, v)
+		}
+	}
+	if len(params) == 0 {
+		return fmt.Errorf("aperture D%d has no size", code)
+	}
+	// scale the sizes, but not the number of vertices and the rotation of
+	// polygons
+	for j := range params {
+		if shape != 'P' || j == 0 {
+			params[j] *= im.scale
+		}
+	}
+	im.apertures[code] = aperture{shape, params}
+	return nil
+}
+
+// Statement executes a function code statement, as in X100Y200D01
+func (im *GerberImporter) Statement(st string) error {
+	if strings.HasPrefix(st, "G04") || strings.HasPrefix(st, "G4") {
+		// comment
+		return nil
+	}
+	words, err := gerberWords(st)
+	if err != nil {
+		return err
+	}
+
+	to := im.pos
+	offset := Vector{}
+	coords := false
+	op := 0
+	for _, w := range words {
+		switch w.letter {
+		case 'G':
+			switch w.value {
+			case "01", "1":
+				im.interp = 1
+			case "02", "2":
+				im.interp = 2
+			case "03", "3":
+				im.interp = 3
+			case "36":
+				im.region = true
+				im.contour = nil
+			case "37":
+				im.closeContour()
+				im.region = false
+			case "70":
+				im.scale = 25.4
+			case "71":
+				im.scale = 1
+			case "74":
+				im.quadrant = true
+			case "75":
+				im.quadrant = false
+			}
+		case 'D':
+			d, err := strconv.Atoi(w.value)
+			if err != nil {
+				return err
+			}
+			if d >= 10 {
+				im.current = d
+			} else {
+				op = d
+			}
+		case 'X':
+			if to.X, err = im.coordinate(w.value); err != nil {
+				return err
+			}
+			coords = true
+		case 'Y':
+			if to.Y, err = im.coordinate(w.value); err != nil {
+				return err
+			}
+			coords = true
+		case 'I':
+			if offset.X, err = im.coordinate(w.value); err != nil {
+				return err
+			}
+		case 'J':
+			if offset.Y, err = im.coordinate(w.value); err != nil {
+				return err
+			}
+		case 'M':
+			// end of file
+		}
+	}
+	if op == 0 {
+		if !coords {
+			return nil
+		}
+		// deprecated modal operation
+		op = im.op
+	}
+	im.op = op
+
+	switch op {
+	case 1:
+		im.draw(to, offset)
+	case 2:
+		if im.region {
+			im.closeContour()
+		}
+	case 3:
+		im.flash(to)
+	}
+	im.pos = to
+	return nil
+}
+
+type gerberWord struct {
+	letter byte
+	value  string
+}
+
+// gerberWords splits a statement into letters followed by numbers
+func gerberWords(st string) ([]gerberWord, error) {
+	words := []gerberWord{}
+	for i := 0; i < len(st); {
+		if !isLetter(st[i]) {
+			return nil, fmt.Errorf("unexpected character %q", st[i])
+		}
+		j := i + 1
+		for j < len(st) && !isLetter(st[j]) {
+			j++
+		}
+		words = append(words, gerberWord{st[i], st[i+1 : j]})
+		i = j
+	}
+	return words, nil
+}
+
+// coordinate converts a coordinate to millimetres, according to the format
+// specification
+func (im *GerberImporter) coordinate(s string) (float64, error) {
+	if strings.Contains(s, ".") {
+		v, err := strconv.ParseFloat(s, 64)
+		return v * im.scale, err
+	}
+	sign := 1.0
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+	if im.trailing {
+		for len(s) < im.intDigits+im.decDigits {
+			s += "0"
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return sign * v / math.Pow10(im.decDigits) * im.scale, err
+}
+
+// arc returns the arc from the current point to p, with the center at offset
+// from the current point
+func (im *GerberImporter) arc(to, offset Vector) *Arc {
+	cw := im.interp == 2
+	if !im.quadrant {
+		return &Arc{im.pos, to, im.pos.Sum(offset), cw}
+	}
+	// in single quadrant mode, the signs of the offset are implicit: pick the
+	// center giving an arc of 90° or less
+	var best *Arc
+	bestErr := math.Inf(1)
+	for _, s := range []Vector{{1, 1, 0}, {1, -1, 0}, {-1, 1, 0}, {-1, -1, 0}} {
+		c := im.pos.Sum(Vector{math.Abs(offset.X) * s.X, math.Abs(offset.Y) * s.Y, 0})
+		a := &Arc{im.pos, to, c, cw}
+		if math.Abs(a.Sweep()) > math.Pi/2+TOLERANCE {
+			continue
+		}
+		err := math.Abs(to.Diff(c).Norm() - a.radius())
+		if err < bestErr {
+			best, bestErr = a, err
+		}
+	}
+	if best == nil {
+		return &Arc{im.pos, to, im.pos.Sum(offset), cw}
+	}
+	return best
+}
+
+// draw handles D01
+func (im *GerberImporter) draw(to, offset Vector) {
+	var m Move
+	if im.interp == 1 {
+		if to.near(im.pos) {
+			return
+		}
+		m = &Line{im.pos, to}
+	} else {
+		a := im.arc(to, offset)
+		if a.From.near(a.To) {
+			// full circle, split in two halves
+			mid := a.at(math.Pi)
+			if im.region {
+				im.contour = append(im.contour, &Arc{a.From, mid, a.Center, a.CW}, &Arc{mid, a.To, a.Center, a.CW})
+			} else {
+				im.add(stroke(&Arc{a.From, mid, a.Center, a.CW}, im.width()/2)...)
+				im.add(stroke(&Arc{mid, a.To, a.Center, a.CW}, im.width()/2)...)
+			}
+			return
+		}
+		m = a
+	}
+
+	if im.region {
+		im.contour = append(im.contour, m)
+		return
+	}
+
+	ap, ok := im.apertures[im.current]
+	if ok && ap.shape == 'R' && im.interp == 1 {
+		// rectangle dragged along a line: convex hull of both ends
+		w, h := ap.params[0]/2, ap.params[1]/2
+		pts := []Vector{}
+		for _, c := range []Vector{im.pos, to} {
+			pts = append(pts, c.Sum(Vector{-w, -h, 0}), c.Sum(Vector{w, -h, 0}), c.Sum(Vector{w, h, 0}), c.Sum(Vector{-w, h, 0}))
+		}
+		im.add([]Path{polygon(hull(pts)...)})
+		return
+	}
+	im.add(stroke(m, im.width()/2)...)
+}
+
+// width returns the size of the current aperture, used for draws
+func (im *GerberImporter) width() float64 {
+	if ap, ok := im.apertures[im.current]; ok {
+		return ap.params[0]
+	}
+	Log.Printf("Undefined aperture D%d\n", im.current)
+	return 0
+}
+
+// flash handles D03
+func (im *GerberImporter) flash(at Vector) {
+	ap, ok := im.apertures[im.current]
+	if !ok {
+		Log.Printf("Undefined aperture D%d\n", im.current)
+		im.Ignored++
+		return
+	}
+	p := ap.params
+	switch ap.shape {
+	case 'C':
+		im.add([]Path{circle(at, p[0]/2)})
+	case 'R', 'O':
+		w, h := p[0]/2, p[0]/2
+		if len(p) > 1 {
+			h = p[1] / 2
+		}
+		if ap.shape == 'R' {
+			im.add([]Path{polygon(
+				at.Sum(Vector{-w, -h, 0}), at.Sum(Vector{w, -h, 0}),
+				at.Sum(Vector{w, h, 0}), at.Sum(Vector{-w, h, 0}),
+			)})
+		} else if w > h {
+			im.add([]Path{stadium(at.Sum(Vector{h - w, 0, 0}), at.Sum(Vector{w - h, 0, 0}), h)})
+		} else {
+			im.add([]Path{stadium(at.Sum(Vector{0, w - h, 0}), at.Sum(Vector{0, h - w, 0}), w)})
+		}
+	case 'P':
+		n, rot := 3, 0.0
+		if len(p) > 1 {
+			n = int(p[1])
+		}
+		if len(p) > 2 {
+			rot = deg2rad(p[2])
+		}
+		pts := make([]Vector, n)
+		for i := range pts {
+			pts[i] = pol2car(rot+2*math.Pi*float64(i)/float64(n), p[0]/2).Sum(at)
+		}
+		im.add([]Path{polygon(pts...)})
+	}
+}
+
+// closeContour ends the contour of the current region
+func (im *GerberImporter) closeContour() {
+	if len(im.contour) > 0 {
+		if im.contour.IsClosed() {
+			im.add([]Path{counterClockwise(im.contour)})
+		} else {
+			Log.Println("Ignored open region contour")
+			im.Ignored++
+		}
+	}
+	im.contour = nil
+}
+
+// add registers copper features
+func (im *GerberImporter) add(shapes ...[]Path) {
+	if !im.dark {
+		Log.Println("Ignored feature with clear polarity")
+		im.Ignored++
+		return
+	}
+	im.shapes = append(im.shapes, shapes...)
+	im.Imported++
+}
+
+// hull returns the convex hull of a set of points, counter-clockwise
+func hull(pts []Vector) []Vector {
+	sort.Slice(pts, func(i, j int) bool {
+		return pts[i].X < pts[j].X || (pts[i].X == pts[j].X && pts[i].Y < pts[j].Y)
+	})
+	h := make([]Vector, 0, 2*len(pts))
+	// lower hull, then upper hull
+	for pass := 0; pass < 2; pass++ {
+		start := len(h)
+		for _, p := range pts {
+			for len(h) >= start+2 && h[len(h)-1].Diff(h[len(h)-2]).cross(p.Diff(h[len(h)-2])) <= 0 {
+				h = h[:len(h)-1]
+			}
+			h = append(h, p)
+		}
+		h = h[:len(h)-1]
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return h
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const gerberPads = `G04 two pads and a trace*
+%FSLAX26Y26*%
+%MOMM*%
+%ADD10C,1.000000*%
+%ADD11C,0.200000*%
+D10*
+X0Y0D03*
+X5000000Y0D03*
+D11*
+X0Y0D02*
+X5000000Y0D01*
+M02*
+`
+
+func TestGerberUnion(t *testing.T) {
+	im := NewGerberImporter()
+	m, stats, err := im.Import(strings.NewReader(gerberPads))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Imported)
+	assert.Len(t, *m, 1, "pads and trace should be merged")
+	// two discs of radius 0.5 joined by a 0.2 wide trace, minus the parts of
+	// the trace covered by the discs
+	covered := 0.1*math.Sqrt(0.24) + 0.25*math.Asin(0.2)
+	expected := 2*math.Pi*0.25 + 5*0.2 - 2*covered
+	assert.InDelta(t, expected, (*m)[0].Area(), 1e-6)
+}
+
+func TestGerberRegion(t *testing.T) {
+	src := `%FSLAX24Y24*%%MOIN*%G36*X0Y0D02*X10000Y0D01*X10000Y10000D01*X0Y10000D01*X0Y0D01*G37*M02*`
+	im := NewGerberImporter()
+	m, _, err := im.Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Len(t, *m, 1)
+	assert.InDelta(t, 25.4*25.4, (*m)[0].Area(), 1e-6)
+}
+
+func TestGerberCoordinates(t *testing.T) {
+	im := NewGerberImporter()
+	assert.NoError(t, im.Statement("X1.5Y2.5D02"))
+	assert.Equal(t, Vector{1.5, 2.5, 0}, im.pos)
+	assert.Error(t, im.Statement("X1.5.2Y2D02"), "malformed coordinates are refused")
+}
+
+func TestExcellon(t *testing.T) {
+	src := "M48\nMETRIC,TZ\nT1C0.8\nT2C1.0\n%\nT1\nX1.0Y2.0\nX3.0\nT2\nX-1.5Y0.5\nM30\n"
+	im := NewExcellonImporter()
+	m, stats, err := im.Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, stats.Imported)
+	drills := []*Drill{}
+	for _, p := range *m {
+		drills = append(drills, p[0].(*Drill))
+	}
+	assert.Equal(t, []*Drill{
+		{Vector{1, 2, 0}, 0.8},
+		{Vector{3, 2, 0}, 0.8},
+		{Vector{-1.5, 0.5, 0}, 1},
+	}, drills)
+	assert.Len(t, im.Layers(), 2, "one layer per tool")
+}
+
+func TestExcellonImplicitDecimals(t *testing.T) {
+	im := NewExcellonImporter()
+	im.Line("INCH,LZ")
+	im.Line("X01Y0025")
+	_, stats, _ := im.Import(strings.NewReader(""))
+	assert.Equal(t, 1, stats.Imported)
+	d := (*flatten(im.Layers()))[0][0].(*Drill)
+	assert.InDelta(t, 25.4, d.At.X, 1e-9)
+	assert.InDelta(t, 0.25*25.4, d.At.Y, 1e-9)
+}
@@ -0,0 +1,256 @@
+package main
+
+// This file contains the code to import HPGL files, as produced for plotters
+// and vinyl cutters. Only the subset of instructions describing geometry is
+// supported: IN, SP, PU, PD, PA, PR, AA, AR and CI. Other instructions are
+// ignored.
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// plotter units per millimetre
+const hpglUnits = 40.0
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "hpgl",
+		Extensions: []string{".plt", ".hpgl", ".hpg", ".hgl"},
+		Magic:      isHPGL,
+		New:        func() Importer { return NewHPGLImporter() },
+	})
+}
+
+// isHPGL returns true if the header starts with one of the instructions
+// usually found at the beginning of an HPGL file.
+func isHPGL(head []byte) bool {
+	s := strings.TrimSpace(string(head))
+	if strings.HasPrefix(s, "\x1b.") {
+		return true
+	}
+	for _, prefix := range []string{"IN", "DF", "SP", "PU", "PA"} {
+		if strings.HasPrefix(s, prefix) && (len(s) == 2 || !isLetter(s[2])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z')
+}
+
+type HPGLImporter struct {
+	Stats
+	Precision int
+	layers    map[string]*Model
+	pen       int    // selected pen
+	down      bool   // pen down
+	relative  bool   // relative coordinates (PR)
+	pos       Vector // current position in plotter units
+}
+
+func NewHPGLImporter() *HPGLImporter {
+	return &HPGLImporter{
+		Precision: 3,
+		layers:    map[string]*Model{},
+		pen:       1,
+	}
+}
+
+func (im *HPGLImporter) Import(stream io.Reader) (*Model, Stats, error) {
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, im.Stats, err
+	}
+
+	Log.Println("Importing instructions")
+	for i := 0; i < len(data); {
+		c := data[i]
+		// device control instructions, ESC . x ... :
+		if c == 0x1b {
+			for i < len(data) && data[i] != ':' && data[i] != ';' {
+				i++
+			}
+			i++
+			continue
+		}
+		if !isLetter(c) || i+1 >= len(data) || !isLetter(data[i+1]) {
+			i++
+			continue
+		}
+		cmd := strings.ToUpper(string(data[i : i+2]))
+		i += 2
+		// labels are terminated by ETX
+		if cmd == "LB" {
+			for i < len(data) && data[i] != 0x03 {
+				i++
+			}
+			i++
+			im.Ignored++
+			continue
+		}
+		j := i
+		for j < len(data) && data[j] != ';' && !isLetter(data[j]) {
+			j++
+		}
+		params, err := hpglParams(string(data[i:j]))
+		if err != nil {
+			return nil, im.Stats, fmt.Errorf("hpgl: %s: %v", cmd, err)
+		}
+		if j < len(data) && data[j] == ';' {
+			j++
+		}
+		i = j
+		im.Instruction(cmd, params)
+	}
+
+	for _, m := range im.layers {
+		m.Merge()
+	}
+	return flatten(im.layers), im.Stats, nil
+}
+
+// Layers returns the imported models, one layer per pen
+func (im *HPGLImporter) Layers() map[string]*Model {
+	return im.layers
+}
+
+// hpglParams parses a list of numbers separated by commas or spaces
+func hpglParams(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	params := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = v
+	}
+	return params, nil
+}
+
+// Instruction executes a single HPGL instruction
+func (im *HPGLImporter) Instruction(cmd string, params []float64) {
+	switch cmd {
+	case "IN":
+		im.down = false
+		im.relative = false
+		im.pos = Vector{}
+	case "SP":
+		if len(params) > 0 {
+			im.pen = int(params[0])
+		}
+	case "PU":
+		im.down = false
+		im.plot(params)
+	case "PD":
+		im.down = true
+		im.plot(params)
+	case "PA":
+		im.relative = false
+		im.plot(params)
+	case "PR":
+		im.relative = true
+		im.plot(params)
+	case "AA", "AR":
+		if len(params) < 3 {
+			Log.Printf("Ignored instruction %s with %d parameters\n", cmd, len(params))
+			im.Ignored++
+			return
+		}
+		center := Vector{params[0], params[1], 0}
+		if cmd == "AR" {
+			center = center.Sum(im.pos)
+		}
+		im.arc(center, deg2rad(params[2]))
+	case "CI":
+		if len(params) < 1 {
+			Log.Printf("Ignored instruction %s with %d parameters\n", cmd, len(params))
+			im.Ignored++
+			return
+		}
+		im.circle(params[0])
+	default:
+		Log.Printf("Ignored instruction %s\n", cmd)
+		im.Ignored++
+	}
+}
+
+// plot moves through the given coordinates, drawing lines if the pen is down
+func (im *HPGLImporter) plot(params []float64) {
+	for i := 0; i+1 < len(params); i += 2 {
+		to := Vector{params[i], params[i+1], 0}
+		if im.relative {
+			to = to.Sum(im.pos)
+		}
+		if im.down && to != im.pos {
+			im.append(&Line{im.point(im.pos), im.point(to)})
+		}
+		im.pos = to
+	}
+}
+
+// arc moves along an arc around center, drawing it if the pen is down. A
+// positive sweep angle (in radians) is counter-clockwise.
+func (im *HPGLImporter) arc(center Vector, sweep float64) {
+	sweep = math.Max(-2*math.Pi, math.Min(2*math.Pi, sweep))
+	angle, radius := car2pol(im.pos.Diff(center))
+	to := pol2car(angle+sweep, radius).Sum(center)
+	if im.down && radius > 0 && sweep != 0 {
+		from := im.point(im.pos)
+		c := im.point(center)
+		if math.Abs(sweep) == 2*math.Pi {
+			// full circle, split in two halves
+			mid := im.point(pol2car(angle+sweep/2, radius).Sum(center))
+			im.append(Path{
+				&Arc{from, mid, c, sweep < 0},
+				&Arc{mid, from, c, sweep < 0},
+			})
+		} else {
+			im.append(&Arc{from, im.point(to), c, sweep < 0})
+		}
+	}
+	if math.Abs(sweep) != 2*math.Pi {
+		im.pos = to
+	}
+}
+
+// circle draws a circle around the current position, the pen is always down
+// and the current position doesn't change.
+func (im *HPGLImporter) circle(radius float64) {
+	center := im.point(im.pos)
+	a := im.point(im.pos.Sum(Vector{radius, 0, 0}))
+	b := im.point(im.pos.Sum(Vector{-radius, 0, 0}))
+	im.append(Path{
+		&Arc{a, b, center, false},
+		&Arc{b, a, center, false},
+	})
+}
+
+// point converts plotter units to millimetres
+func (im *HPGLImporter) point(v Vector) Vector {
+	pre := math.Pow10(im.Precision)
+	x := math.Floor(v.X/hpglUnits*pre) / pre
+	y := math.Floor(v.Y/hpglUnits*pre) / pre
+	return Vector{x, y, 0}
+}
+
+// append adds a move to the layer of the current pen
+func (im *HPGLImporter) append(mo Move) {
+	name := fmt.Sprintf("pen%d", im.pen)
+	m, ok := im.layers[name]
+	if !ok {
+		m = &Model{}
+		im.layers[name] = m
+	}
+	m.Append(mo)
+	im.Imported++
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHPGLSquare(t *testing.T) {
+	im := NewHPGLImporter()
+	src := "IN;SP1;PU0,0;PD400,0,400,400,0,400,0,0;PU;"
+	m, stats, err := im.Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stats.Imported)
+	assert.Len(t, *m, 1, "square should be a single path")
+	assert.True(t, (*m)[0].IsClosed(), "square should be closed")
+	_, to := (*m)[0][0].Move()
+	assert.Equal(t, Vector{10, 0, 0}, to, "plotter units not converted to mm")
+}
+
+func TestHPGLPens(t *testing.T) {
+	im := NewHPGLImporter()
+	src := "IN;SP1;PA0,0;PD;PR400,0;PU;SP2;PA800,800;CI400;"
+	_, _, err := im.Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	layers := im.Layers()
+	assert.Len(t, layers, 2)
+	assert.Equal(t, &Model{Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}}}, layers["pen1"])
+	circle := *layers["pen2"]
+	assert.Len(t, circle, 1)
+	assert.Equal(t, Vector{30, 20, 0}, circle[0][0].(*Arc).From)
+	assert.Equal(t, Vector{20, 20, 0}, circle[0][0].(*Arc).Center)
+}
+
+func TestHPGLArc(t *testing.T) {
+	im := NewHPGLImporter()
+	src := "IN;PA400,0;PD;AA0,0,90;"
+	m, _, err := im.Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	a := (*m)[0][0].(*Arc)
+	assert.Equal(t, false, a.CW, "positive sweep is CCW")
+	assert.InDelta(t, 0, a.To.X, 1e-3)
+	assert.InDelta(t, 10, a.To.Y, 1e-3)
+}
+
+func TestDetectHPGL(t *testing.T) {
+	f, err := DetectFormat("job", []byte("IN;SP1;PU0,0;"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hpgl", f.Name)
+}
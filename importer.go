@@ -0,0 +1,142 @@
+package main
+
+// This file contains the plumbing used to pick the right importer for a file,
+// either from its extension or from its first bytes.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Importer converts a stream into a Model
+type Importer interface {
+	Import(stream io.Reader) (*Model, Stats, error)
+}
+
+// Layered is implemented by importers that keep track of the layer each path
+// was found on. Layers must be called after Import.
+type Layered interface {
+	Layers() map[string]*Model
+}
+
+// DefaultLayer is the name of the layer used when the format has no layers
+const DefaultLayer = "0"
+
+// Stats are the statistics collected by an importer
+type Stats struct {
+	Imported  int // number of imported entities
+	Ignored   int // number of ignored entities
+	Discarded int // number of discarded entities (duplicates)
+	Empty     int // number of zero-length moves removed
+	Merged    int // number of moves merged with their neighbours, see Cleanup
+}
+
+// Log prints the statistics
+func (s Stats) Log() {
+	Log.Println("Imported entities: ", s.Imported)
+	Log.Println("Ignored entities:  ", s.Ignored)
+	Log.Println("Discarded entities:", s.Discarded)
+	Log.Println("Empty moves:       ", s.Empty)
+	Log.Println("Merged moves:      ", s.Merged)
+}
+
+// Format describes a file format that can be imported
+type Format struct {
+	Name       string
+	Extensions []string          // lowercase, with the leading dot
+	Magic      func([]byte) bool // recognizes the first bytes of a file, can be nil
+	New        func() Importer
+}
+
+// sniffLen is the number of bytes passed to Format.Magic
+const sniffLen = 512
+
+var formats []Format
+
+// RegisterFormat makes a format available to ImportLayers. Formats registered
+// first take precedence when several of them match.
+func RegisterFormat(f Format) {
+	formats = append(formats, f)
+}
+
+// DetectFormat finds the format of a file, first by looking at the extension
+// of its name, then by looking at its first bytes.
+func DetectFormat(name string, head []byte) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, nil
+			}
+		}
+	}
+	for _, f := range formats {
+		if f.Magic != nil && f.Magic(head) {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown format for %s", name)
+}
+
+// NewImporter returns the importer matching the stream, and a reader to use in
+// place of the stream.
+func NewImporter(name string, stream io.Reader) (Importer, io.Reader, error) {
+	r := bufio.NewReaderSize(stream, sniffLen)
+	head, err := r.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	f, err := DetectFormat(name, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	Log.Println("Importing", name, "as", f.Name)
+	return f.New(), r, nil
+}
+
+// ImportLayers detects the format of stream and imports it with the matching
+// importer, which is returned with the model of each layer. Formats without
+// layers put everything on DefaultLayer. The name is only used to detect the
+// format. Duplicates and zero-length moves are removed from every layer, which
+// loses nothing, see Cleanup.
+func ImportLayers(name string, stream io.Reader) (Importer, map[string]*Model, Stats, error) {
+	im, r, err := NewImporter(name, stream)
+	if err != nil {
+		return nil, nil, Stats{}, err
+	}
+	model, stats, err := im.Import(r)
+	if err != nil {
+		return nil, nil, stats, err
+	}
+	layers := map[string]*Model{DefaultLayer: model}
+	if l, ok := im.(Layered); ok {
+		layers = l.Layers()
+	}
+	for _, m := range layers {
+		*m = Cleanup{}.unique(*m, &stats)
+	}
+	return im, layers, stats, nil
+}
+
+// layerNames returns the names of the layers, sorted
+func layerNames(layers map[string]*Model) []string {
+	names := make([]string, 0, len(layers))
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flatten concatenates the models of all layers, sorted by layer name
+func flatten(layers map[string]*Model) *Model {
+	m := &Model{}
+	for _, name := range layerNames(layers) {
+		*m = append(*m, *layers[name]...)
+	}
+	return m
+}
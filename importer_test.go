@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormatExtension(t *testing.T) {
+	f, err := DetectFormat("logo.DXF", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "dxf", f.Name, "extension should be case insensitive")
+}
+
+func TestDetectFormatMagic(t *testing.T) {
+	f, err := DetectFormat("logo", []byte("  0\nSECTION\n  2\nHEADER\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "dxf", f.Name, "DXF header not recognized")
+}
+
+func TestDetectFormatUnknown(t *testing.T) {
+	_, err := DetectFormat("logo", []byte("garbage"))
+	assert.Error(t, err, "should not detect a format")
+}
+
+func TestImportLayersDuplicates(t *testing.T) {
+	hpgl := "IN;SP1;PU0,0;PD400,0;PU400,0;PD0,0;PU0,0;PD0,0;"
+	_, layers, stats, err := ImportLayers("twice.hpgl", strings.NewReader(hpgl))
+	assert.NoError(t, err)
+	assert.Len(t, *flatten(layers), 1)
+	assert.Equal(t, 1, stats.Discarded, "the line is drawn twice")
+}
@@ -0,0 +1,329 @@
+package main
+
+// This file contains a Gcode interpreter, used to rebuild toolpaths from Gcode
+// produced by other programs. It keeps track of the modal state of the machine
+// (motion, distance mode, units, plane, feed, speed and G92 offsets) and turns
+// cutting moves into passes.
+//
+// Each pass has the depth of the move starting it, and the heights of its moves
+// are made relative to that depth, so ramps and helices keep their slope.
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/joushou/gocnc/gcode"
+)
+
+func init() {
+	RegisterFormat(Format{
+		Name:       "gcode",
+		Extensions: []string{".ngc", ".nc", ".gcode", ".gc", ".tap", ".cnc"},
+		Magic:      nil,
+		New:        func() Importer { return NewGcodeImporter() },
+	})
+}
+
+// Interpreter executes Gcode blocks and records the resulting toolpath
+type Interpreter struct {
+	Motion   int     // modal motion: 0, 1, 2 or 3
+	Relative bool    // G91
+	Scale    float64 // millimetres per unit: 1 for G21, 25.4 for G20
+	Plane    int     // 17, 18 or 19
+	Feed     float64
+	Speed    float64
+	Tool     int        // tool selected by the last T word
+	Offset   [3]float64 // G92 offsets
+	Pos      [3]float64 // current position, without offsets
+	Toolpath Toolpath
+
+	pass   *Pass   // pass being built
+	dwell  float64 // pause before the next pass, in seconds
+	rapidZ float64 // lowest height of rapid moves in X and Y
+	rapids bool
+}
+
+func NewInterpreter() *Interpreter {
+	return &Interpreter{
+		Scale: 1,
+		Plane: 17,
+	}
+}
+
+// Run executes all the blocks of a document
+func (in *Interpreter) Run(doc gcode.Document) error {
+	for i, b := range doc.Blocks {
+		if err := in.Block(b); err != nil {
+			return fmt.Errorf("block %d: %v", i+1, err)
+		}
+	}
+	in.end()
+	if in.rapids {
+		// rapid moves are made at safe height
+		in.Toolpath.SafeZ = in.rapidZ
+	}
+	return nil
+}
+
+// Block executes a single block
+func (in *Interpreter) Block(b gcode.Block) error {
+	if b.BlockDelete {
+		return nil
+	}
+
+	words := map[rune]float64{}
+	gs := []float64{}
+	for _, n := range b.Nodes {
+		w, ok := n.(*gcode.Word)
+		if !ok {
+			continue
+		}
+		if w.Address == 'G' {
+			gs = append(gs, w.Command)
+		} else {
+			words[w.Address] = w.Command
+		}
+	}
+
+	// modal settings come before motion
+	g92 := false
+	for _, g := range gs {
+		switch g {
+		case 0, 1, 2, 3:
+			in.Motion = int(g)
+		case 17, 18, 19:
+			in.Plane = int(g)
+		case 20:
+			in.Scale = 25.4
+		case 21:
+			in.Scale = 1
+		case 90:
+			in.Relative = false
+		case 91:
+			in.Relative = true
+		case 92:
+			g92 = true
+		case 92.1, 92.2:
+			in.Offset = [3]float64{}
+		case 4:
+			// the pause belongs to the next pass
+			in.end()
+			in.dwell += words['P']
+		case 10, 28, 30, 40, 43, 49, 53, 54, 55, 56, 57, 58, 59, 64, 80, 94:
+			// no effect on the toolpath
+		default:
+			Log.Printf("Ignored G%g\n", g)
+		}
+	}
+	if f, ok := words['F']; ok {
+		in.Feed = f * in.Scale
+	}
+	if s, ok := words['S']; ok {
+		in.Speed = s
+	}
+	if t, ok := words['T']; ok {
+		in.Tool = int(t)
+	}
+
+	target := in.Pos
+	moved := false
+	for i, axis := range []rune{'X', 'Y', 'Z'} {
+		v, ok := words[axis]
+		if !ok {
+			continue
+		}
+		moved = true
+		v *= in.Scale
+		switch {
+		case g92:
+			// the current position becomes v
+			in.Offset[i] = in.Pos[i] - v
+		case in.Relative:
+			target[i] += v
+		default:
+			target[i] = v + in.Offset[i]
+		}
+	}
+	if g92 || !moved {
+		return nil
+	}
+
+	switch in.Motion {
+	case 0:
+		in.end()
+		if target[0] != in.Pos[0] || target[1] != in.Pos[1] {
+			z := math.Min(in.Pos[2], target[2])
+			if !in.rapids || z < in.rapidZ {
+				in.rapidZ = z
+			}
+			in.rapids = true
+		}
+	case 1:
+		in.feed(&Line{in.point(in.Pos), in.point(target)})
+	case 2, 3:
+		a, err := in.arc(target, words)
+		if err != nil {
+			return err
+		}
+		in.feed(a)
+	}
+	in.Pos = target
+	return nil
+}
+
+func (in *Interpreter) point(p [3]float64) Vector {
+	return Vector{p[0], p[1], p[2]}
+}
+
+// arc builds the arc going from the current position to target, with the
+// center given by I and J, or by R. Arcs changing height are helices.
+func (in *Interpreter) arc(target [3]float64, words map[rune]float64) (Move, error) {
+	from, to := in.point(in.Pos), in.point(target)
+	cw := in.Motion == 2
+	if in.Plane != 17 {
+		Log.Printf("Arc in plane G%d replaced by a line\n", in.Plane)
+		return &Line{from, to}, nil
+	}
+
+	if r, ok := words['R']; ok {
+		r *= in.Scale
+		d := to.Diff(from)
+		l := d.Norm()
+		if l == 0 {
+			return nil, fmt.Errorf("R arc with identical start and end")
+		}
+		h := math.Sqrt(math.Max(0, r*r-l*l/4))
+		// positive R: arc of 180° or less, the center is on the right of the
+		// chord for CW arcs, on the left for CCW arcs
+		n := Vector{-d.Y, d.X, 0}.Divide(l)
+		if cw != (r < 0) {
+			n = n.Multiply(-1)
+		}
+		center := from.Sum(d.Divide(2)).Sum(n.Multiply(h))
+		center.Z = 0
+		return &Arc{from, to, center, cw}, nil
+	}
+
+	i, iok := words['I']
+	j, jok := words['J']
+	if !iok && !jok {
+		return nil, fmt.Errorf("arc without center")
+	}
+	center := from.Sum(Vector{i * in.Scale, j * in.Scale, 0})
+	center.Z = 0
+	if to.near(from) {
+		// full circle, split in two halves
+		mid := center.Diff(from.Diff(center))
+		mid.Z = (from.Z + to.Z) / 2
+		return Path{&Arc{from, mid, center, cw}, &Arc{mid, to, center, cw}}, nil
+	}
+	return &Arc{from, to, center, cw}, nil
+}
+
+// feed records a cutting move. Moves at the depth of the current pass, and
+// ramps and helices, are added to it; the heights of the moves are made
+// relative to the depth of the pass.
+func (in *Interpreter) feed(m Move) {
+	from, to := m.Move()
+	if from.near(to) {
+		if _, ok := m.(Path); !ok {
+			// plunge or retract, the next move starts a new pass
+			in.end()
+			return
+		}
+	}
+	depth := -from.Z
+	p := in.pass
+	if p == nil || (from.Z == to.Z && p.Depth != depth) || p.Feed != in.Feed || p.Speed != in.Speed || p.Tool != in.Tool || in.dwell > 0 {
+		in.end()
+		in.pass = &Pass{
+			Operation: "gcode",
+			Depth:     depth,
+			Feed:      in.Feed,
+			Speed:     in.Speed,
+			Tool:      in.Tool,
+			Dwell:     in.dwell,
+		}
+		in.dwell = 0
+	}
+	ms := []Move{m}
+	if path, ok := m.(Path); ok {
+		ms = path
+	}
+	for _, m := range ms {
+		relative(m, in.pass.Depth)
+		in.pass.Path = append(in.pass.Path, m)
+	}
+}
+
+// relative makes the heights of a line or an arc relative to a depth
+func relative(m Move, depth float64) {
+	switch m := m.(type) {
+	case *Line:
+		m.From.Z += depth
+		m.To.Z += depth
+	case *Arc:
+		m.From.Z += depth
+		m.To.Z += depth
+	}
+}
+
+// end closes the current pass
+func (in *Interpreter) end() {
+	if in.pass != nil && len(in.pass.Path) > 0 {
+		in.Toolpath.Passes = append(in.Toolpath.Passes, *in.pass)
+	}
+	in.pass = nil
+	in.Toolpath.SafeZ = math.Max(in.Toolpath.SafeZ, in.Pos[2])
+}
+
+// ReadGcode parses Gcode and returns the toolpath it describes
+func ReadGcode(stream io.Reader) (*Interpreter, error) {
+	data, err := ioutil.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := gcode.Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	in := NewInterpreter()
+	if err := in.Run(*doc); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// GcodeImporter imports the paths followed by the tool in a Gcode file. Paths
+// machined in several passes only appear once in the model.
+type GcodeImporter struct {
+	Stats
+	Toolpath Toolpath
+}
+
+func NewGcodeImporter() *GcodeImporter {
+	return &GcodeImporter{}
+}
+
+func (im *GcodeImporter) Import(stream io.Reader) (*Model, Stats, error) {
+	in, err := ReadGcode(stream)
+	if err != nil {
+		return nil, im.Stats, err
+	}
+	im.Toolpath = in.Toolpath
+	m := &Model{}
+	var last Path
+	for _, p := range in.Toolpath.Passes {
+		im.Imported += len(p.Path)
+		if last != nil && last.Equal(p.Path) {
+			im.Discarded += len(p.Path)
+			continue
+		}
+		// the model does not share its moves with the toolpath
+		*m = append(*m, p.Path.Clone())
+		last = p.Path
+	}
+	return m, im.Stats, nil
+}
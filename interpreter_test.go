@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGcodeRoundTrip(t *testing.T) {
+	op := Operation{Name: "gcode", Kind: Engrave, Depth: 2, PassDepth: 1, Feed: 500}
+	m := Model{
+		circle(Vector{5, 5, 0}, 2),
+		Path{&Line{Vector{0, 0, 0}, Vector{10, 0, 0}}, &Line{Vector{10, 0, 0}, Vector{10, 10, 0}}},
+	}
+	passes, err := op.Passes(m)
+	assert.NoError(t, err)
+	tp := Toolpath{SafeZ: 5, Passes: passes}
+	doc := tp.Gcode()
+
+	in, err := ReadGcode(strings.NewReader(doc.Export(3)))
+	assert.NoError(t, err)
+	assert.Equal(t, tp, in.Toolpath, "toolpath should survive a round trip")
+}
+
+func TestGcodeModalState(t *testing.T) {
+	src := `G20 G90
+G0 Z0.2
+G0 X1 Y0
+G1 Z-0.1 F10
+G91 G1 X1
+G2 X1 Y-1 R1
+G90 G3 X3 Y-1 I0 J1
+`
+	in, err := ReadGcode(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Len(t, in.Toolpath.Passes, 1)
+	p := in.Toolpath.Passes[0]
+	assert.InDelta(t, 2.54, p.Depth, 1e-9)
+	assert.InDelta(t, 254, p.Feed, 1e-9)
+	assert.Len(t, p.Path, 4)
+	assert.Equal(t, &Line{Vector{25.4, 0, 0}, Vector{50.8, 0, 0}}, p.Path[0])
+
+	r := p.Path[1].(*Arc)
+	assert.True(t, r.CW)
+	assert.InDelta(t, 50.8, r.Center.X, 1e-9)
+	assert.InDelta(t, -25.4, r.Center.Y, 1e-9)
+
+	// full circle in IJ form, split in two
+	ij := p.Path[2].(*Arc)
+	assert.False(t, ij.CW)
+	assert.InDelta(t, 25.4, ij.To.Y, 1e-9)
+	assert.Equal(t, ij.From, p.Path[3].(*Arc).To)
+}
+
+func TestGcodeImporter(t *testing.T) {
+	src := "G0 X0 Y0\nG1 Z-1\nG1 X1\nG0 Z5\nG0 X0 Y0\nG1 Z-2\nG1 X1\nG0 Z5\n"
+	m, stats, err := NewGcodeImporter().Import(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Len(t, *m, 1, "passes following the same path should be merged")
+	assert.Equal(t, 1, stats.Discarded)
+}
+
+func TestGcodeRampsAndHelices(t *testing.T) {
+	src := `G0 X0 Y0
+G1 Z0 F100
+G1 X10 Z-1
+G1 X0
+G2 X0 Y0 I5 J0 Z-2
+G1 X10
+`
+	in, err := ReadGcode(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Len(t, in.Toolpath.Passes, 3)
+
+	// the ramp starts a pass at its start depth
+	ramp := in.Toolpath.Passes[0]
+	assert.Equal(t, 0.0, ramp.Depth)
+	assert.Equal(t, &Line{Vector{0, 0, 0}, Vector{10, 0, -1}}, ramp.Path[0])
+
+	// the flat move and the helix keep the depth of the flat move
+	p := in.Toolpath.Passes[1]
+	assert.Equal(t, 1.0, p.Depth)
+	assert.Len(t, p.Path, 3)
+	helix := p.Path[1].(*Arc)
+	assert.Equal(t, 0.0, helix.From.Z)
+	assert.InDelta(t, -0.5, helix.To.Z, 1e-9, "the full turn is split in the middle of the descent")
+	assert.Equal(t, -1.0, p.Path[2].(*Arc).To.Z)
+
+	assert.Equal(t, 2.0, in.Toolpath.Passes[2].Depth)
+
+	// heights survive a round trip
+	doc := in.Toolpath.Gcode()
+	out, err := ReadGcode(strings.NewReader(doc.Export(3)))
+	assert.NoError(t, err)
+	assert.Equal(t, in.Toolpath.Passes, out.Toolpath.Passes)
+}
@@ -0,0 +1,233 @@
+package main
+
+// This file contains the computation of intersections between moves, and the
+// splitting of moves at those intersections.
+
+import (
+	"math"
+	"sort"
+)
+
+// intersect returns the points where two moves meet. When the moves overlap,
+// the ends of the overlapping part are returned. Points close to the ends of
+// the moves are moved onto them, so shared vertices stay exactly shared.
+func intersect(m1, m2 Move) []Vector {
+	pts := intersectMoves(m1, m2)
+	from1, to1 := m1.Move()
+	from2, to2 := m2.Move()
+	for i, p := range pts {
+		for _, e := range []Vector{from1, to1, from2, to2} {
+			if p.Diff(e).Norm() <= 10*TOLERANCE {
+				pts[i] = e
+				break
+			}
+		}
+	}
+	return pts
+}
+
+func intersectMoves(m1, m2 Move) []Vector {
+	switch m1 := m1.(type) {
+	case *Line:
+		switch m2 := m2.(type) {
+		case *Line:
+			return intersectLines(m1, m2)
+		case *Arc:
+			return intersectLineArc(m1, m2)
+		}
+	case *Arc:
+		switch m2 := m2.(type) {
+		case *Line:
+			return intersectLineArc(m2, m1)
+		case *Arc:
+			return intersectArcs(m1, m2)
+		}
+	}
+	return nil
+}
+
+// onLine returns true if p lies on the segment l
+func onLine(l *Line, p Vector) bool {
+	return distance(l, p) <= TOLERANCE
+}
+
+// onArc returns true if p lies on the arc a
+func onArc(a *Arc, p Vector) bool {
+	return math.Abs(p.Diff(a.Center).Norm()-a.radius()) <= TOLERANCE && a.covers(p)
+}
+
+func intersectLines(l1, l2 *Line) []Vector {
+	d1 := l1.To.Diff(l1.From)
+	d2 := l2.To.Diff(l2.From)
+	den := d1.cross(d2)
+	w := l2.From.Diff(l1.From)
+
+	if math.Abs(den) <= TOLERANCE*d1.Norm()*d2.Norm() {
+		// parallel lines, look for an overlap
+		pts := []Vector{}
+		for _, p := range []Vector{l2.From, l2.To} {
+			if onLine(l1, p) {
+				pts = append(pts, p)
+			}
+		}
+		for _, p := range []Vector{l1.From, l1.To} {
+			if onLine(l2, p) {
+				pts = append(pts, p)
+			}
+		}
+		return pts
+	}
+
+	t := w.cross(d2) / den
+	p := l1.From.Sum(d1.Multiply(t))
+	if onLine(l1, p) && onLine(l2, p) {
+		return []Vector{p}
+	}
+	return nil
+}
+
+func intersectLineArc(l *Line, a *Arc) []Vector {
+	d := l.To.Diff(l.From)
+	n := d.Norm()
+	if n == 0 {
+		return nil
+	}
+	u := d.Divide(n)
+	r := a.radius()
+	// closest point of the line to the center
+	t0 := a.Center.Diff(l.From).Dot(u)
+	closest := l.From.Sum(u.Multiply(t0))
+	h := closest.Diff(a.Center).Norm()
+
+	var candidates []Vector
+	switch {
+	case h > r+TOLERANCE:
+		return nil
+	case h >= r-TOLERANCE:
+		// tangent
+		candidates = []Vector{closest}
+	default:
+		s := math.Sqrt(r*r - h*h)
+		candidates = []Vector{
+			closest.Diff(u.Multiply(s)),
+			closest.Sum(u.Multiply(s)),
+		}
+	}
+
+	pts := []Vector{}
+	for _, p := range candidates {
+		if onLine(l, p) && a.covers(p) {
+			pts = append(pts, p)
+		}
+	}
+	return pts
+}
+
+func intersectArcs(a1, a2 *Arc) []Vector {
+	r1, r2 := a1.radius(), a2.radius()
+	v := a2.Center.Diff(a1.Center)
+	d := v.Norm()
+
+	if d <= TOLERANCE {
+		if math.Abs(r1-r2) > TOLERANCE {
+			return nil
+		}
+		// same circle, look for an overlap
+		pts := []Vector{}
+		for _, p := range []Vector{a2.From, a2.To} {
+			if a1.covers(p) {
+				pts = append(pts, p)
+			}
+		}
+		for _, p := range []Vector{a1.From, a1.To} {
+			if a2.covers(p) {
+				pts = append(pts, p)
+			}
+		}
+		return pts
+	}
+
+	if d > r1+r2+TOLERANCE || d < math.Abs(r1-r2)-TOLERANCE {
+		return nil
+	}
+
+	// distance from the center of a1 to the chord joining the intersections
+	x := (d*d + r1*r1 - r2*r2) / (2 * d)
+	u := v.Divide(d)
+	base := a1.Center.Sum(u.Multiply(x))
+
+	var candidates []Vector
+	h2 := r1*r1 - x*x
+	if h2 <= 2*r1*TOLERANCE {
+		// tangent
+		candidates = []Vector{base}
+	} else {
+		h := math.Sqrt(h2)
+		n := Vector{-u.Y, u.X, 0}
+		candidates = []Vector{
+			base.Sum(n.Multiply(h)),
+			base.Diff(n.Multiply(h)),
+		}
+	}
+
+	pts := []Vector{}
+	for _, p := range candidates {
+		if a1.covers(p) && a2.covers(p) {
+			pts = append(pts, p)
+		}
+	}
+	return pts
+}
+
+// position returns the position of p along the move, as a value growing from
+// the start to the end of the move.
+func position(m Move, p Vector) float64 {
+	if a, ok := m.(*Arc); ok {
+		return a.offsetAngle(p) * a.radius()
+	}
+	from, to := m.Move()
+	return p.Diff(from).Dot(to.Diff(from))
+}
+
+// splitPoints returns the points that lie strictly inside the move, sorted in
+// the direction of travel, without duplicates.
+func splitPoints(m Move, pts []Vector) []Vector {
+	from, to := m.Move()
+	inner := []Vector{}
+	for _, p := range pts {
+		if p.near(from) || p.near(to) {
+			continue
+		}
+		inner = append(inner, p)
+	}
+	sort.SliceStable(inner, func(i, j int) bool {
+		return position(m, inner[i]) < position(m, inner[j])
+	})
+	res := []Vector{}
+	for _, p := range inner {
+		if len(res) == 0 || !res[len(res)-1].near(p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// split cuts a move at the given points, which must lie on the move
+func split(m Move, pts []Vector) []Move {
+	pts = splitPoints(m, pts)
+	if len(pts) == 0 {
+		return []Move{m}
+	}
+	from, to := m.Move()
+	parts := make([]Move, 0, len(pts)+1)
+	for _, p := range append(pts, to) {
+		switch m := m.(type) {
+		case *Arc:
+			parts = append(parts, &Arc{from, p, m.Center, m.CW})
+		default:
+			parts = append(parts, &Line{from, p})
+		}
+		from = p
+	}
+	return parts
+}
@@ -0,0 +1,318 @@
+package main
+
+// This file contains the jobs: JSON files listing the files to machine, with
+// their layers and transforms, and the operations to run on them, in order,
+// so a job can be run again without typing all the options.
+//
+//	{
+//		"safe_z": 5,
+//		"library": "tools.json",
+//		"material": "plywood",
+//		"sources": [
+//			{"name": "box", "file": "box.dxf", "rotate": 90, "offset": [10, 10]}
+//		],
+//		"operations": [
+//			{"name": "holes", "type": "drill", "layers": ["holes"], "tool": 3, "depth": 6},
+//			{"name": "cut", "type": "profile", "layers": ["outline"], "tool": 3,
+//			 "depth": 6, "pass_depth": 2, "feed": 800, "tabs": {"count": 4, "width": 5, "height": 1}},
+//			{"name": "recess", "type": "pocket", "layers": ["recess"], "tool_number": 1, "depth": 2}
+//		]
+//	}
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Job describes the files to machine, and how to machine them
+type Job struct {
+	SafeZ      float64        `json:"safe_z"`      // height of rapid moves, 5 if 0
+	Library    string         `json:"library"`     // tool library, relative to the job file
+	Material   string         `json:"material"`    // material in the library, giving speeds and feeds
+	GroupTools bool           `json:"group_tools"` // run the passes of each tool together, rather than in the order of the operations
+	Sources    []JobSource    `json:"sources"`
+	Operations []JobOperation `json:"operations"`
+
+	dir string // directory of the job file, source files are relative to it
+}
+
+// JobSource is a file of the job
+type JobSource struct {
+	Name   string     `json:"name"`   // used by operations to refer to the source
+	File   string     `json:"file"`   // relative to the job file
+	Layers []string   `json:"layers"` // layers to import, all if empty
+	Mirror string     `json:"mirror"` // x or y, none if empty
+	Rotate float64    `json:"rotate"` // counterclockwise, in degrees
+	Scale  float64    `json:"scale"`  // 1 if 0
+	Offset [2]float64 `json:"offset"` // translation applied last
+}
+
+// JobOperation is an operation of the job, see Operation
+type JobOperation struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`    // engrave, profile, pocket, rest, drill or vcarve
+	Sources    []string `json:"sources"` // names of the sources, all if empty
+	Layers     []string `json:"layers"`  // layers of the sources, all if empty
+	Tool       float64  `json:"tool"`
+	ToolNumber int      `json:"tool_number"` // tool of the library, replacing tool and angle
+	Angle      float64  `json:"angle"`
+	Depth      float64  `json:"depth"`
+	PassDepth  float64  `json:"pass_depth"`
+	Feed       float64  `json:"feed"`
+	PlungeFeed float64  `json:"plunge_feed"`
+	Speed      float64  `json:"speed"`
+	Dwell      float64  `json:"dwell"` // pause before each plunge, in seconds
+	StepOver   float64  `json:"step_over"`
+	Previous   float64  `json:"previous_tool"` // larger tool that cleared the pockets, for rest machining
+	Tolerance  float64  `json:"tolerance"`     // sampling of the boundaries of v-carvings
+	Tabs       Tabs     `json:"tabs"`
+	Order      string   `json:"order"` // nearest (the default) or file
+}
+
+// ReadJob reads a job file
+func ReadJob(name string) (*Job, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	job := &Job{dir: filepath.Dir(name)}
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(job); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return job, nil
+}
+
+// Operation returns the operation described by o
+func (o JobOperation) Operation() (Operation, error) {
+	op := Operation{
+		Name:       o.Name,
+		Kind:       o.Type,
+		Tool:       o.Tool,
+		Angle:      o.Angle,
+		Depth:      o.Depth,
+		PassDepth:  o.PassDepth,
+		Feed:       o.Feed,
+		PlungeFeed: o.PlungeFeed,
+		Speed:      o.Speed,
+		Dwell:      o.Dwell,
+		StepOver:   o.StepOver,
+		Previous:   o.Previous,
+		Tolerance:  o.Tolerance,
+		Tabs:       o.Tabs,
+	}
+	if op.Name == "" {
+		op.Name = o.Type
+	}
+	switch o.Order {
+	case "", "nearest":
+		op.Optimize = true
+	case "file":
+	default:
+		return op, fmt.Errorf("unknown order %s", o.Order)
+	}
+	return op, nil
+}
+
+// Transform returns the transform applied to the source
+func (s JobSource) Transform() (Transform, error) {
+	t := Identity()
+	switch s.Mirror {
+	case "x":
+		t = MirrorX()
+	case "y":
+		t = MirrorY()
+	case "":
+	default:
+		return t, fmt.Errorf("unknown mirror %s", s.Mirror)
+	}
+	scale := s.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return t.Then(Rotation(deg2rad(s.Rotate))).
+		Then(Scaling(scale)).
+		Then(Translation(Vector{s.Offset[0], s.Offset[1], 0})), nil
+}
+
+// resolve returns the path of a file named relatively to dir
+func resolve(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// load imports the source, and returns its transformed layers
+func (s JobSource) load(dir string) (map[string]*Model, error) {
+	name := resolve(dir, s.File)
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, layers, stats, err := ImportLayers(name, file)
+	if err != nil {
+		return nil, err
+	}
+	stats.Log()
+	if len(s.Layers) > 0 {
+		if layers, err = pick(layers, s.Layers); err != nil {
+			return nil, err
+		}
+	}
+	t, err := s.Transform()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range layers {
+		m.Transform(t)
+	}
+	return layers, nil
+}
+
+// pick returns the layers with the given names, all of them being needed
+func pick(layers map[string]*Model, names []string) (map[string]*Model, error) {
+	res := map[string]*Model{}
+	for _, name := range names {
+		m, ok := layers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown layer %s", name)
+		}
+		res[name] = m
+	}
+	return res, nil
+}
+
+// Run imports the sources and runs the operations. It returns the model made
+// of all the sources, and the toolpath. Operations are run in order, unless
+// GroupTools is set, so each tool is loaded once.
+func (j *Job) Run() (Model, Toolpath, error) {
+	tp := Toolpath{SafeZ: j.SafeZ}
+	if tp.SafeZ == 0 {
+		tp.SafeZ = 5
+	}
+
+	var lib *Library
+	if j.Library != "" {
+		var err error
+		if lib, err = ReadLibrary(resolve(j.dir, j.Library)); err != nil {
+			return nil, tp, err
+		}
+	}
+
+	sources := map[string]map[string]*Model{}
+	names := []string{}
+	all := Model{}
+	for i, s := range j.Sources {
+		if s.Name == "" {
+			s.Name = s.File
+		}
+		names = append(names, s.Name)
+		if _, ok := sources[s.Name]; ok {
+			return nil, tp, fmt.Errorf("source %d: duplicate name %s", i, s.Name)
+		}
+		layers, err := s.load(j.dir)
+		if err != nil {
+			return nil, tp, fmt.Errorf("source %s: %v", s.Name, err)
+		}
+		sources[s.Name] = layers
+		all = append(all, *flatten(layers)...)
+	}
+
+	// tools not in the library are numbered by kind and size, after the
+	// numbers used
+	used := map[int]bool{}
+	for _, o := range j.Operations {
+		used[o.ToolNumber] = true
+	}
+	numbers := map[string]int{}
+	next := 1
+	number := func(op Operation) int {
+		var key string
+		switch op.Kind {
+		case Drilling:
+			t := Tool{Shape: DrillBit, Angle: op.Angle}
+			t.setDefaults()
+			key = fmt.Sprintf("drill %g mm %g°", op.Tool, t.Angle)
+		case VCarving:
+			key = fmt.Sprintf("v bit %g mm %g°", op.Tool, op.Angle)
+		default:
+			key = fmt.Sprintf("end mill %g mm", op.Tool)
+		}
+		n, ok := numbers[key]
+		if !ok {
+			for used[next] {
+				next++
+			}
+			n, used[next] = next, true
+			numbers[key] = n
+			Log.Printf("Tool %d: %s\n", n, key)
+		}
+		return n
+	}
+
+	for i, o := range j.Operations {
+		op, err := o.Operation()
+		if err != nil {
+			return nil, tp, fmt.Errorf("operation %d: %v", i, err)
+		}
+		if o.ToolNumber != 0 {
+			if lib == nil {
+				return nil, tp, fmt.Errorf("operation %s: tool %d without a library", op.Name, o.ToolNumber)
+			}
+			t, err := lib.Setup(&op, o.ToolNumber, j.Material)
+			if err != nil {
+				return nil, tp, fmt.Errorf("operation %s: %v", op.Name, err)
+			}
+			for _, err := range op.Check(t) {
+				Log.Println(err)
+			}
+		} else {
+			op.ToolNumber = number(op)
+		}
+		used := o.Sources
+		if len(used) == 0 {
+			used = names
+		}
+		// each layer must be found in one of the sources at least
+		m := Model{}
+		found := map[string]bool{}
+		for _, name := range used {
+			layers, ok := sources[name]
+			if !ok {
+				return nil, tp, fmt.Errorf("operation %s: unknown source %s", op.Name, name)
+			}
+			if len(o.Layers) > 0 {
+				on := map[string]*Model{}
+				for _, l := range o.Layers {
+					if lm, ok := layers[l]; ok {
+						on[l], found[l] = lm, true
+					}
+				}
+				layers = on
+			}
+			m = append(m, *flatten(layers)...)
+		}
+		for _, l := range o.Layers {
+			if !found[l] {
+				return nil, tp, fmt.Errorf("operation %s: unknown layer %s", op.Name, l)
+			}
+		}
+		passes, err := op.Passes(m)
+		if err != nil {
+			return nil, tp, fmt.Errorf("operation %s: %v", op.Name, err)
+		}
+		tp.Passes = append(tp.Passes, passes...)
+	}
+	if j.GroupTools {
+		tp = tp.Grouped()
+	}
+	return all, tp, nil
+}
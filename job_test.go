@@ -0,0 +1,178 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJob = `{
+	"safe_z": 3,
+	"sources": [
+		{"name": "plate", "file": "plate.hpgl", "offset": [5, 5]}
+	],
+	"operations": [
+		{"name": "recess", "type": "pocket", "layers": ["pen2"], "tool": 2, "depth": 1, "feed": 500},
+		{"name": "cut", "type": "profile", "sources": ["plate"], "layers": ["pen1"], "tool": 2,
+		 "depth": 3, "pass_depth": 1.5, "feed": 800, "tabs": {"count": 2, "width": 3, "height": 1}}
+	]
+}`
+
+func writeJob(t *testing.T, job string) string {
+	dir, err := ioutil.TempDir("", "gocam")
+	assert.NoError(t, err)
+	hpgl := "IN;SP1;PU0,0;PD1600,0,1600,1600,0,1600,0,0;PU;SP2;PA800,800;CI200;"
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "plate.hpgl"), []byte(hpgl), 0644))
+	name := filepath.Join(dir, "job.json")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(job), 0644))
+	return name
+}
+
+func TestJob(t *testing.T) {
+	name := writeJob(t, testJob)
+	defer os.RemoveAll(filepath.Dir(name))
+
+	job, err := ReadJob(name)
+	assert.NoError(t, err)
+	m, tp, err := job.Run()
+	assert.NoError(t, err)
+	assert.Len(t, m, 2)
+	assert.Equal(t, 3.0, tp.SafeZ)
+
+	pockets, cuts := 0, 0
+	for _, p := range tp.Passes {
+		switch p.Operation {
+		case "recess":
+			pockets++
+			assert.Equal(t, 1.0, p.Depth)
+			assert.Equal(t, 500.0, p.Feed)
+		case "cut":
+			cuts++
+			b := p.Path.Bounds()
+			assert.InDelta(t, 4, b.Min.X, 1e-6, "the source is moved by its offset")
+		}
+	}
+	assert.Equal(t, 4, pockets)
+	assert.Equal(t, 2, cuts)
+	assert.Equal(t, "recess", tp.Passes[0].Operation, "operations run in order")
+
+	// the same file gives the same program
+	doc := tp.Gcode()
+	for i := 0; i < 3; i++ {
+		job, err := ReadJob(name)
+		assert.NoError(t, err)
+		_, again, err := job.Run()
+		assert.NoError(t, err)
+		other := again.Gcode()
+		assert.Equal(t, doc.Export(3), other.Export(3))
+	}
+}
+
+func TestJobErrors(t *testing.T) {
+	name := writeJob(t, `{"sources": [{"file": "plate.hpgl"}], "operations": [{"type": "profile", "sources": ["other"]}]}`)
+	defer os.RemoveAll(filepath.Dir(name))
+	job, err := ReadJob(name)
+	assert.NoError(t, err)
+	_, _, err = job.Run()
+	assert.Error(t, err)
+	assert.Equal(t, "operation profile: unknown source other", err.Error())
+
+	job.Operations = []JobOperation{{Type: "profile", Layers: []string{"pen3"}}}
+	_, _, err = job.Run()
+	assert.Error(t, err)
+	assert.Equal(t, "operation profile: unknown layer pen3", err.Error())
+
+	job.Operations = nil
+	job.Sources[0].Layers = []string{"pen1", "pen3"}
+	_, _, err = job.Run()
+	assert.Error(t, err)
+	assert.Equal(t, "source plate.hpgl: unknown layer pen3", err.Error())
+
+	name = writeJob(t, `{"operations": [{"type": "profile", "depht": 1}]}`)
+	defer os.RemoveAll(filepath.Dir(name))
+	_, err = ReadJob(name)
+	assert.Error(t, err, "unknown fields are refused")
+}
+
+func TestJobLibrary(t *testing.T) {
+	name := writeJob(t, `{
+		"library": "tools.json",
+		"material": "plywood",
+		"sources": [{"file": "plate.hpgl"}],
+		"operations": [{"type": "profile", "layers": ["pen1"], "tool_number": 2, "depth": 3}]
+	}`)
+	defer os.RemoveAll(filepath.Dir(name))
+	writeLibrary(t, filepath.Dir(name), testLibrary)
+
+	job, err := ReadJob(name)
+	assert.NoError(t, err)
+	_, tp, err := job.Run()
+	assert.NoError(t, err)
+	assert.Len(t, tp.Passes, 1)
+	assert.Equal(t, 10610.0, tp.Passes[0].Speed)
+	assert.Equal(t, 1061.0, tp.Passes[0].Feed)
+	b := tp.Passes[0].Path.Bounds()
+	assert.InDelta(t, -3, b.Min.X, 1e-6, "the profile is offset by the radius of the tool")
+
+	job.Library = ""
+	_, _, err = job.Run()
+	assert.Error(t, err, "tool numbers need a library")
+}
+
+func TestJobToolNumbers(t *testing.T) {
+	name := writeJob(t, `{
+		"sources": [{"name": "plate", "file": "plate.hpgl"}],
+		"operations": [
+			{"type": "pocket", "layers": ["pen2"], "tool": 6, "depth": 1},
+			{"type": "profile", "layers": ["pen1"], "tool": 3, "depth": 3},
+			{"type": "engrave", "layers": ["pen2"], "tool": 6, "depth": 0.5}
+		]
+	}`)
+	defer os.RemoveAll(filepath.Dir(name))
+
+	job, err := ReadJob(name)
+	assert.NoError(t, err)
+	_, tp, err := job.Run()
+	assert.NoError(t, err)
+	// tools without number are numbered by diameter, the operations keeping
+	// their order
+	assert.Equal(t, []int{1, 2}, tp.tools())
+	assert.Equal(t, 1, tp.Passes[len(tp.Passes)-1].Tool)
+	assert.Len(t, tp.Split(), 2)
+
+	tp.Change = ToolChange{Kind: ToolChanger}
+	doc := tp.Gcode()
+	assert.Equal(t, 3, strings.Count(doc.Export(3), "M6"))
+
+	job.GroupTools = true
+	_, tp, err = job.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tp.Passes[len(tp.Passes)-1].Tool)
+	tp.Change = ToolChange{Kind: ToolChanger}
+	doc = tp.Gcode()
+	assert.Equal(t, 2, strings.Count(doc.Export(3), "M6"), "each tool is loaded once")
+}
+
+func TestJobToolKinds(t *testing.T) {
+	name := writeJob(t, `{
+		"sources": [{"name": "plate", "file": "plate.hpgl"}],
+		"operations": [
+			{"type": "drill", "layers": ["pen2"], "tool": 3, "depth": 2},
+			{"type": "profile", "layers": ["pen1"], "tool": 3, "depth": 3},
+			{"type": "drill", "layers": ["pen2"], "tool": 3, "depth": 4}
+		]
+	}`)
+	defer os.RemoveAll(filepath.Dir(name))
+
+	job, err := ReadJob(name)
+	assert.NoError(t, err)
+	_, tp, err := job.Run()
+	assert.NoError(t, err)
+	// a drill and an end mill of the same diameter are different tools
+	assert.Equal(t, []int{1, 2}, tp.tools())
+	assert.Equal(t, 1, tp.Passes[len(tp.Passes)-1].Tool)
+}
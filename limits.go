@@ -0,0 +1,100 @@
+package main
+
+// This file contains the validation of a toolpath against the work envelope of
+// the machine and the size of the stock, so mistakes are caught before the
+// tool hits the clamps or the spoilboard.
+
+import "fmt"
+
+// Limits describes where the tool is allowed to go. Zero values disable the
+// corresponding checks.
+type Limits struct {
+	Min, Max [3]float64 // work envelope of the machine, in work coordinates
+	Stock    [3]float64 // size of the stock, from the origin; its top is at Z=0
+	SafeZ    float64    // minimum height of rapid moves
+	MaxDepth float64    // maximum depth of cut, the stock thickness if 0
+}
+
+// passBounds returns the bounding box of the tool center during a pass
+func passBounds(p Pass) Box {
+	b := p.Path.Bounds()
+	b.Min.Z -= p.Depth
+	b.Max.Z -= p.Depth
+	return b
+}
+
+// Bounds returns the bounding box of the tool center, including rapid moves
+// at safe height, from the origin where the program starts
+func (tp Toolpath) Bounds() Box {
+	start := Vector{0, 0, tp.SafeZ}
+	b := Box{start, start}
+	for _, p := range tp.Passes {
+		b = b.union(passBounds(p))
+	}
+	return b
+}
+
+// axis returns the coordinate of v on the axis i, from 0 for X to 2 for Z
+func axis(v Vector, i int) float64 {
+	return [3]float64{v.X, v.Y, v.Z}[i]
+}
+
+// Check returns the problems found in the toolpath
+func (l Limits) Check(tp Toolpath) []error {
+	if len(tp.Passes) == 0 {
+		return nil
+	}
+	errs := []error{}
+	axes := "XYZ"
+
+	b := tp.Bounds()
+	for i := range l.Min {
+		if l.Min[i] == l.Max[i] {
+			continue
+		}
+		min, max := axis(b.Min, i), axis(b.Max, i)
+		if min < l.Min[i]-EPSILON || max > l.Max[i]+EPSILON {
+			errs = append(errs, fmt.Errorf("%c axis from %g to %g, out of the machine envelope (%g to %g)",
+				axes[i], min, max, l.Min[i], l.Max[i]))
+		}
+	}
+
+	// manual tool changes are made at their own position
+	if c := tp.Change; c.Kind == ManualChange && c.At != nil && len(tp.tools()) > 1 {
+		for i := range l.Min {
+			if l.Min[i] == l.Max[i] {
+				continue
+			}
+			if v := axis(*c.At, i); v < l.Min[i]-EPSILON || v > l.Max[i]+EPSILON {
+				errs = append(errs, fmt.Errorf("tool change at %c %g, out of the machine envelope (%g to %g)",
+					axes[i], v, l.Min[i], l.Max[i]))
+			}
+		}
+	}
+
+	if l.SafeZ > 0 && tp.SafeZ < l.SafeZ {
+		errs = append(errs, fmt.Errorf("rapid moves at %g, below the safe height %g", tp.SafeZ, l.SafeZ))
+	}
+
+	maxDepth := l.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = l.Stock[2]
+	}
+	for i, p := range tp.Passes {
+		pb := passBounds(p)
+		if depth := -pb.Min.Z; maxDepth > 0 && depth > maxDepth+EPSILON {
+			errs = append(errs, fmt.Errorf("pass %d at depth %g, below the maximum depth %g", i, depth, maxDepth))
+		}
+		if l.Stock[0] == 0 || l.Stock[1] == 0 {
+			continue
+		}
+		for j := 0; j < 2; j++ {
+			min, max := axis(pb.Min, j), axis(pb.Max, j)
+			if min < -EPSILON || max > l.Stock[j]+EPSILON {
+				errs = append(errs, fmt.Errorf("pass %d: %c from %g to %g, out of the stock (0 to %g)",
+					i, axes[j], min, max, l.Stock[j]))
+			}
+		}
+	}
+	return errs
+}
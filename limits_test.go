@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolpathBounds(t *testing.T) {
+	tp := Toolpath{SafeZ: 5, Passes: []Pass{
+		{Depth: 1, Path: circle(Vector{10, 10, 0}, 5)},
+		{Depth: 3, Path: Path{&Line{Vector{0, 2, 0}, Vector{4, 2, 0}}}},
+	}}
+	b := tp.Bounds()
+	// the program starts at the origin
+	assert.Equal(t, Vector{0, 0, -3}, b.Min)
+	assert.Equal(t, Vector{15, 15, 5}, b.Max)
+
+	// empty paths are skipped
+	tp.Passes = append(tp.Passes, Pass{Depth: 8})
+	assert.Equal(t, b, tp.Bounds())
+	assert.True(t, (Path{}).Bounds().Empty())
+	tp.Passes = nil
+	assert.Equal(t, Box{Vector{0, 0, 5}, Vector{0, 0, 5}}, tp.Bounds())
+}
+
+func TestLimitsCheck(t *testing.T) {
+	tp := Toolpath{SafeZ: 2, Passes: []Pass{
+		{Depth: 1, Path: circle(Vector{10, 10, 0}, 5)},
+		{Depth: 6, Path: Path{&Line{Vector{0, 2, 0}, Vector{4, 2, 0}}}},
+	}}
+	assert.Empty(t, Limits{}.Check(tp), "no limits, no problems")
+
+	ok := Limits{Min: [3]float64{0, 0, -10}, Max: [3]float64{100, 100, 50}, Stock: [3]float64{20, 20, 10}, SafeZ: 1}
+	assert.Empty(t, ok.Check(tp))
+
+	l := Limits{Min: [3]float64{0, 0, -5}, Max: [3]float64{12, 100, 50}, Stock: [3]float64{14, 20, 10}, SafeZ: 3, MaxDepth: 5}
+	errs := l.Check(tp)
+	// X and Z out of the envelope, rapids too low, pass 1 too deep, pass
+	// 0 out of the stock in X
+	assert.Len(t, errs, 5)
+}
+
+func TestLimitsToolChange(t *testing.T) {
+	tp := Toolpath{SafeZ: 2, Passes: []Pass{
+		{Depth: 1, Tool: 1, Path: Path{&Line{Vector{0, 2, 0}, Vector{4, 2, 0}}}},
+		{Depth: 1, Tool: 2, Path: Path{&Line{Vector{0, 2, 0}, Vector{4, 2, 0}}}},
+	}}
+	tp.Change = ToolChange{Kind: ManualChange, At: &Vector{50, 0, 120}}
+	l := Limits{Min: [3]float64{0, 0, -10}, Max: [3]float64{100, 100, 100}}
+	errs := l.Check(tp)
+	assert.Len(t, errs, 1, "the change is above the envelope")
+
+	tp.Passes[1].Tool = 1
+	assert.Empty(t, l.Check(tp), "a single tool is never changed")
+}
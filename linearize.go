@@ -0,0 +1,74 @@
+package main
+
+// This file contains the replacement of arcs by straight lines, for
+// controllers that do not support G2 and G3.
+
+import "math"
+
+// Linearization describes how arcs are replaced by lines
+type Linearization struct {
+	Tolerance float64 // maximum distance between a chord and the arc, 0 for no limit
+	MaxLength float64 // maximum length of a chord, 0 for no limit
+}
+
+// segments returns the number of chords replacing an arc
+func (l Linearization) segments(a *Arc) int {
+	r, sweep := a.radius(), math.Abs(a.Sweep())
+	n := 1
+	if l.Tolerance > 0 && l.Tolerance < r {
+		step := 2 * math.Acos(1-l.Tolerance/r)
+		n = int(math.Ceil(sweep / step))
+	}
+	if l.MaxLength > 0 {
+		n = int(math.Max(float64(n), math.Ceil(sweep*r/l.MaxLength)))
+	}
+	// a single chord would cut through half circles
+	if n < 2 && sweep > math.Pi-TOLERANCE {
+		n = 2
+	}
+	return n
+}
+
+// Arc returns the chords replacing an arc. They start and end exactly on the
+// ends of the arc.
+func (l Linearization) Arc(a *Arc) Path {
+	n := l.segments(a)
+	sweep := math.Abs(a.Sweep())
+	p := Path{}
+	from := a.From
+	for i := 1; i <= n; i++ {
+		to := a.To
+		if i < n {
+			to = a.at(sweep * float64(i) / float64(n))
+		}
+		p = append(p, &Line{from, to})
+		from = to
+	}
+	return p
+}
+
+// Path returns a copy of the path where arcs are replaced by lines
+func (l Linearization) Path(p Path) Path {
+	res := Path{}
+	for _, m := range p {
+		switch m := m.(type) {
+		case *Arc:
+			res = append(res, l.Arc(m)...)
+		case Path:
+			res = append(res, l.Path(m)...)
+		default:
+			res = append(res, clone(m))
+		}
+	}
+	return res
+}
+
+// Toolpath returns a copy of the toolpath where arcs are replaced by lines
+func (l Linearization) Toolpath(tp Toolpath) Toolpath {
+	res := Toolpath{SafeZ: tp.SafeZ, Passes: make([]Pass, len(tp.Passes)), Arcs: tp.Arcs, Change: tp.Change}
+	for i, p := range tp.Passes {
+		p.Path = l.Path(p.Path)
+		res.Passes[i] = p
+	}
+	return res
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinearizeTolerance(t *testing.T) {
+	a := &Arc{Vector{10, 0, 0}, Vector{-10, 0, 0}, Vector{0, 0, 0}, false}
+	l := Linearization{Tolerance: 0.01}
+	p := l.Arc(a)
+
+	from, to := p.Move()
+	assert.Equal(t, a.From, from, "the ends of the arc are kept exactly")
+	assert.Equal(t, a.To, to)
+	for _, m := range p {
+		// the middle of each chord is within tolerance of the arc
+		d := 10 - midpoint(m).Norm()
+		assert.True(t, d <= 0.01+1e-9)
+	}
+	// the chords are as long as possible
+	assert.Len(t, p, int(math.Ceil(math.Pi/(2*math.Acos(1-0.001)))))
+}
+
+func TestLinearizeLength(t *testing.T) {
+	c := circle(Vector{0, 0, 0}, 1)
+	p := Linearization{MaxLength: 0.5}.Path(c)
+	assert.True(t, p.IsClosed())
+	for _, m := range p {
+		from, to := m.Move()
+		assert.True(t, to.Diff(from).Norm() <= 0.5)
+	}
+	// half circles are never replaced by a single line
+	assert.Len(t, Linearization{}.Path(c), 4)
+}
@@ -1,23 +1,419 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
+	"io/ioutil"
+	"math"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// vector is a flag holding a Vector, as x,y
+type vector Vector
+
+func (v *vector) String() string {
+	return fmt.Sprintf("%g,%g", v.X, v.Y)
+}
+
+func (v *vector) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%g,%g", &v.X, &v.Y)
+	return err
+}
+
+// axes is a flag holding a value for each of the X, Y and Z axes
+type axes [3]float64
+
+func (a *axes) String() string {
+	return fmt.Sprintf("%g,%g,%g", a[0], a[1], a[2])
+}
+
+func (a *axes) Set(s string) error {
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return err
+		}
+		a[i] = v
+	}
+	return nil
+}
+
 func main() {
-	fname := os.Args[1]
-	file, err := os.Open(fname)
-	if err != nil {
-		log.Fatal(err)
+	precision := flag.Int("precision", 3, "number of decimals in the output")
+	op := Operation{Name: "main"}
+	flag.StringVar(&op.Kind, "op", Engrave, "operation: engrave, profile, pocket, rest, drill or vcarve")
+	flag.Float64Var(&op.Tool, "tool", 3, "diameter of the tool")
+	flag.Float64Var(&op.Depth, "depth", 1, "final depth of cut")
+	flag.Float64Var(&op.PassDepth, "passdepth", 0, "maximum depth of a pass, 0 for a single pass")
+	flag.Float64Var(&op.Feed, "feed", 0, "feed rate, 0 to leave it unset")
+	flag.Float64Var(&op.PlungeFeed, "plunge", 0, "plunge feed rate, 0 to use the feed rate")
+	flag.Float64Var(&op.Speed, "speed", 0, "spindle speed, 0 to leave it unset")
+	flag.Float64Var(&op.Dwell, "dwell", 0, "pause before each plunge, in seconds")
+	flag.Float64Var(&op.StepOver, "stepover", 0, "distance between the passes of pockets (half the tool if 0) and of the clearing of v-carvings (none if 0)")
+	flag.Float64Var(&op.Previous, "previoustool", 0, "diameter of the larger tool that cleared the pockets, for rest machining")
+	flag.Float64Var(&op.Tolerance, "vtolerance", 0.1, "distance between the samples of the boundaries of v-carvings")
+	flag.IntVar(&op.Tabs.Count, "tabs", 0, "number of tabs along each profile")
+	flag.Float64Var(&op.Tabs.Width, "tabwidth", 5, "width of the tabs")
+	flag.Float64Var(&op.Tabs.Height, "tabheight", 1, "height of the tabs, from the final depth")
+	flag.BoolVar(&op.Optimize, "optimize", true, "machine inner paths first, then the nearest ones")
+	safeZ := flag.Float64("safez", 5, "height of rapid moves")
+	output := flag.String("output", "gcode", "output format: gcode, dxf, svg, info, heightmap (png) or stl")
+	svgTool := flag.Bool("svgtool", false, "draw cuts as wide as the tool in the svg preview")
+	svgColor := flag.String("svgcolor", "depth", "color the cuts of the svg preview by depth or operation")
+	tool := Tool{}
+	flag.StringVar(&tool.Shape, "toolshape", FlatEnd, "shape of the tool: flat, ball, v, drill or engraver")
+	flag.Float64Var(&tool.Angle, "toolangle", 90, "included angle of v bits, engravers and drills, in degrees, 118 for drills if not given")
+	resolution := flag.Float64("simres", 0.1, "resolution of the material removal simulation")
+	simulate := flag.Bool("simulate", false, "compare the material removal simulation to the model in the info output")
+	machine := DefaultMachine()
+	flag.Var((*axes)(&machine.MaxRate), "maxrate", "maximum rate of the X, Y and Z axes, in mm/min")
+	flag.Var((*axes)(&machine.Accel), "accel", "maximum acceleration of the X, Y and Z axes, in mm/s²")
+	flag.Float64Var(&machine.JunctionDeviation, "junction", machine.JunctionDeviation, "junction deviation, in mm")
+	limits := Limits{}
+	flag.Var((*axes)(&limits.Min), "envmin", "lower corner of the machine envelope, in work coordinates")
+	flag.Var((*axes)(&limits.Max), "envmax", "upper corner of the machine envelope, in work coordinates")
+	flag.Var((*axes)(&limits.Stock), "stock", "size of the stock, from the origin, its top at Z=0")
+	flag.Float64Var(&limits.SafeZ, "minsafez", 0, "minimum height of rapid moves, 0 to disable the check")
+	flag.Float64Var(&limits.MaxDepth, "maxdepth", 0, "maximum depth of cut, 0 for the stock thickness")
+	mirror := flag.String("mirror", "", "mirror the model: x flips X coordinates, y flips Y coordinates")
+	rotate := flag.Float64("rotate", 0, "rotate the model counterclockwise, in degrees")
+	scale := flag.Float64("scale", 1, "scale the model")
+	grid := flag.String("grid", "", "copies of the model in columns and rows, as NxM")
+	spacing := Vector{5, 5, 0}
+	flag.Var((*vector)(&spacing), "spacing", "space between the copies of the grid, as x,y")
+	polar := flag.Int("polar", 0, "number of copies of the model around -polarcenter")
+	polarCenter := Vector{}
+	flag.Var((*vector)(&polarCenter), "polarcenter", "center of the polar copies, as x,y")
+	polarAngle := flag.Float64("polarangle", 0, "angle between polar copies in degrees, 0 to spread them over a turn")
+	var stock Vector
+	flag.Var((*vector)(&stock), "nest", "nest the parts on a stock of this size, as width,height")
+	nest := Nesting{}
+	flag.Float64Var(&nest.Spacing, "nestspacing", 5, "distance between nested parts")
+	flag.IntVar(&nest.Rotations, "nestrotations", 4, "number of orientations of nested parts, spread over a turn")
+	flag.Float64Var(&nest.Resolution, "nestres", 1, "resolution of the nesting")
+	origin := flag.String("origin", "", "place the bounding box of the model on the origin: corner or center")
+	lin := Linearization{}
+	flag.Float64Var(&lin.Tolerance, "linearize", 0, "replace arcs by lines within this tolerance, 0 to keep arcs")
+	flag.Float64Var(&lin.MaxLength, "maxsegment", 0, "maximum length of the lines replacing arcs, 0 for no limit")
+	arcs := ArcFormat{}
+	flag.StringVar(&arcs.Form, "arcs", CenterForm, "form of arcs in gcode: ij (center) or r (radius)")
+	flag.Float64Var(&arcs.Tolerance, "arctolerance", 0.002, "maximum difference between the start and end radii of arcs")
+	fillets := Fillets{}
+	flag.StringVar(&fillets.Kind, "fillets", "", "fillets at inside corners of closed paths: dogbone or tbone")
+	flag.Float64Var(&fillets.MaxAngle, "filletangle", 180, "largest angle of the corners receiving a fillet, in degrees")
+	filletLayers := flag.String("filletlayers", "", "layers receiving fillets, separated by commas, all if empty")
+	cleanup := Cleanup{}
+	flag.Float64Var(&cleanup.Tolerance, "simplify", 0, "merge and simplify paths within this tolerance, 0 to disable; duplicates are always removed")
+	gaps := flag.Float64("gaps", 0, "join the ends of open paths closer than this, 0 to disable")
+	jobFile := flag.String("job", "", "run the job file instead of importing files")
+	library := flag.String("library", "", "tool library file")
+	toolNumber := flag.Int("toolnumber", 0, "tool of the library, replacing -tool, -toolshape and -toolangle")
+	material := flag.String("material", "", "material of the library, giving the speed and feed rate left unset")
+	change := ToolChange{}
+	flag.StringVar(&change.Kind, "toolchange", ToolChanger, "tool changes: m6, pause (M0, the first tool being loaded before starting) or none")
+	flag.BoolVar(&change.LengthOffset, "lengthoffset", false, "apply the tool length offset with G43 after M6")
+	var changeAt axes
+	flag.Var(&changeAt, "changepos", "position of the tool during pauses for tool changes, as x,y,z")
+	split := flag.String("split", "", "write the gcode of each tool to its own file, named with this prefix and the tool number")
+	force := flag.Bool("force", false, "write the output even if the toolpath is out of limits")
+	flag.Parse()
+	tool.Diameter = op.Tool
+	switch arcs.Form {
+	case CenterForm, RadiusForm:
+	default:
+		Log.Fatalf("unknown arc form %s", arcs.Form)
 	}
+	switch change.Kind {
+	case ToolChanger, ManualChange:
+	case "none":
+		change.Kind = ""
+	default:
+		Log.Fatalf("unknown tool change %s", change.Kind)
+	}
+	angle := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "changepos":
+			change.At = &Vector{changeAt[0], changeAt[1], changeAt[2]}
+		case "toolangle":
+			angle = true
+		}
+	})
+	if !angle && tool.Shape == DrillBit {
+		// the default of the flag is for v bits
+		tool.Angle = 0
+	}
+	tool.setDefaults()
+	op.Angle = tool.Angle
+	if *toolNumber != 0 {
+		if *library == "" {
+			Log.Fatal("-toolnumber needs a -library")
+		}
+		lib, err := ReadLibrary(*library)
+		if err != nil {
+			Log.Fatal(err)
+		}
+		if tool, err = lib.Setup(&op, *toolNumber, *material); err != nil {
+			Log.Fatal(err)
+		}
+		for _, err := range op.Check(tool) {
+			Log.Println(err)
+		}
+	}
+
+	var model *Model
+	var tp Toolpath
+	if *jobFile != "" {
+		// the job gives its files, how they are placed, and its tools
+		jobFlags := map[string]bool{
+			"mirror": true, "rotate": true, "scale": true, "origin": true,
+			"grid": true, "spacing": true, "polar": true, "polarcenter": true, "polarangle": true,
+			"nest": true, "nestspacing": true, "nestrotations": true, "nestres": true,
+			"fillets": true, "filletangle": true, "filletlayers": true, "simplify": true, "gaps": true,
+			"library": true, "toolnumber": true, "material": true,
+		}
+		flag.Visit(func(f *flag.Flag) {
+			if jobFlags[f.Name] {
+				Log.Fatalf("-%s can not be used with -job", f.Name)
+			}
+		})
+		if flag.NArg() > 0 {
+			Log.Fatal("input files can not be used with -job, they are the sources of the job")
+		}
+		job, err := ReadJob(*jobFile)
+		if err != nil {
+			Log.Fatal(err)
+		}
+		m, jtp, err := job.Run()
+		if err != nil {
+			Log.Fatal(err)
+		}
+		model, tp = &m, jtp
+	} else {
+		if flag.NArg() == 0 {
+			Log.Fatal("no input file")
+		}
+		// the files are merged, with their layers of the same name, which
+		// are kept apart until the fillets are made
+		layers := map[string]*Model{}
+		var gcode *GcodeImporter
+		for _, fname := range flag.Args() {
+			im, l, stats, err := importFile(fname)
+			if err != nil {
+				Log.Fatal(err)
+			}
+			for name, lm := range l {
+				if cleanup.Tolerance > 0 {
+					*lm = cleanup.Model(*lm, &stats)
+				}
+				if layers[name] == nil {
+					layers[name] = &Model{}
+				}
+				*layers[name] = append(*layers[name], *lm...)
+			}
+			stats.Log()
+			if g, ok := im.(*GcodeImporter); ok && flag.NArg() == 1 {
+				gcode = g
+			}
+		}
+		if *gaps > 0 {
+			closed := 0
+			for _, lm := range layers {
+				var n int
+				*lm, n = lm.CloseGaps(*gaps)
+				closed += n
+			}
+			Log.Printf("Closed %d gaps\n", closed)
+		}
 
-	im := NewImporter()
-	if err = im.Import(file); err != nil {
-		Log.Fatal(err)
+		t := Identity()
+		switch *mirror {
+		case "x":
+			t = MirrorX()
+		case "y":
+			t = MirrorY()
+		case "":
+		default:
+			Log.Fatalf("unknown mirror %s", *mirror)
+		}
+		t = t.Then(Rotation(deg2rad(*rotate))).Then(Scaling(*scale))
+		model = flatten(layers)
+		model.Transform(t)
+		if fillets.Kind != "" {
+			if fillets.Kind != Dogbone && fillets.Kind != TBone {
+				Log.Fatalf("unknown fillets %s", fillets.Kind)
+			}
+			fillets.Radius = op.Tool / 2
+			selected, err := onLayers(layers, *filletLayers)
+			if err != nil {
+				Log.Fatal(err)
+			}
+			*model = fillets.Apply(*model, selected)
+		}
+		if *grid != "" {
+			var columns, rows int
+			if _, err := fmt.Sscanf(*grid, "%dx%d", &columns, &rows); err != nil {
+				Log.Fatalf("invalid grid %s", *grid)
+			}
+			*model = model.Grid(columns, rows, spacing)
+		}
+		if *polar > 0 {
+			step := deg2rad(*polarAngle)
+			if step == 0 {
+				step = 2 * math.Pi / float64(*polar)
+			}
+			*model = model.Polar(*polar, polarCenter, step)
+		}
+		placed, err := model.Place(*origin)
+		if err != nil {
+			Log.Fatal(err)
+		}
+		t = t.Then(placed)
+		if stock.X > 0 && stock.Y > 0 {
+			nest.Width, nest.Height = stock.X, stock.Y
+			res := nest.Nest(*model)
+			Log.Printf("Nested %d parts, %.1f%% of the stock used\n", res.Placed, 100*res.Utilisation)
+			for _, u := range res.Unfitted {
+				b := u.Bounds()
+				Log.Printf("Not nested: %d paths from %v to %v\n", len(u), b.Min, b.Max)
+			}
+			*model = res.Model
+		}
+
+		// a single Gcode file keeps its toolpath, unless an operation is given
+		opSet := false
+		flag.Visit(func(f *flag.Flag) {
+			opSet = opSet || f.Name == "op"
+		})
+		if gcode != nil && !opSet {
+			tp = gcode.Toolpath
+			for _, p := range tp.Passes {
+				p.Path.Transform(t)
+			}
+		} else {
+			passes, err := op.Passes(*model)
+			if err != nil {
+				Log.Fatal(err)
+			}
+			tp = Toolpath{SafeZ: *safeZ, Passes: passes}
+		}
+	}
+	tp.Arcs = arcs
+	tp.Change = change
+	if lin.Tolerance > 0 || lin.MaxLength > 0 {
+		tp = lin.Toolpath(tp)
+	}
+
+	estimate := machine.Estimate(tp)
+	problems := append(limits.Check(tp), arcs.Check(tp)...)
+	for _, err := range problems {
+		Log.Println(err)
+	}
+	if len(problems) > 0 && !*force && (*output == "gcode" || *output == "dxf") {
+		Log.Fatal("toolpath out of limits, use -force to write it anyway")
+	}
+
+	switch *output {
+	case "gcode":
+		if *split == "" {
+			doc := tp.Gcode()
+			doc.Blocks = append(estimate.Comments(tp), doc.Blocks...)
+			fmt.Println(doc.Export(*precision))
+			break
+		}
+		for _, t := range tp.Split() {
+			name := fmt.Sprintf("%s-T%d.nc", *split, t.Passes[0].Tool)
+			doc := t.Gcode()
+			doc.Blocks = append(machine.Estimate(t).Comments(t), doc.Blocks...)
+			if err := ioutil.WriteFile(name, []byte(doc.Export(*precision)+"\n"), 0644); err != nil {
+				Log.Fatal(err)
+			}
+			Log.Printf("Wrote %s\n", name)
+		}
+	case "dxf":
+		if err := tp.DXF(os.Stdout, *precision); err != nil {
+			Log.Fatal(err)
+		}
+	case "svg":
+		svg := SVG{Precision: *precision, ColorBy: *svgColor}
+		if *svgTool {
+			svg.Tool = op.Tool
+		}
+		if err := svg.Render(os.Stdout, *model, tp); err != nil {
+			Log.Fatal(err)
+		}
+	case "info":
+		fmt.Printf("Paths:  %d\n", len(*model))
+		for _, o := range model.Openings() {
+			fmt.Printf("  %s\n", o)
+		}
+		fmt.Printf("Passes: %d\n", len(tp.Passes))
+		if len(tp.Passes) > 0 {
+			fmt.Printf("Bounds: %s\n", tp.Bounds())
+		}
+		fmt.Printf("Time:   %s\n", estimate.Total.Round(time.Second))
+		for i, d := range estimate.Passes {
+			p := tp.Passes[i]
+			fmt.Printf("  pass %d: %s at depth %g, %s\n", i, p.Operation, p.Depth, d.Round(time.Second))
+		}
+		if region := orient(*model); *simulate && len(region) > 0 {
+			h := Simulate(tp, tool, limits.Stock, *resolution)
+			fmt.Printf("Simulation: %s\n", h.Compare(region, op.Depth))
+		}
+	case "heightmap":
+		h := Simulate(tp, tool, limits.Stock, *resolution)
+		if err := h.PNG(os.Stdout); err != nil {
+			Log.Fatal(err)
+		}
+	case "stl":
+		h := Simulate(tp, tool, limits.Stock, *resolution)
+		if err := h.STL(os.Stdout); err != nil {
+			Log.Fatal(err)
+		}
+	default:
+		Log.Fatalf("unknown output format %s", *output)
+	}
+}
+
+// onLayers returns a function accepting the indexes of the paths of the model
+// flattened from the layers that are on the given layers, separated by
+// commas, or nil if names is empty.
+func onLayers(layers map[string]*Model, names string) (func(int) bool, error) {
+	if names == "" {
+		return nil, nil
+	}
+	on := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		if _, ok := layers[name]; !ok {
+			return nil, fmt.Errorf("no layer %s", name)
+		}
+		on[name] = true
+	}
+	selected := []bool{}
+	for _, name := range layerNames(layers) {
+		for range *layers[name] {
+			selected = append(selected, on[name])
+		}
+	}
+	return func(i int) bool {
+		return selected[i]
+	}, nil
+}
+
+// importFile imports the layers of a file, and returns its importer
+func importFile(name string) (Importer, map[string]*Model, Stats, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, nil, Stats{}, err
 	}
+	defer file.Close()
 
-	doc := im.Model.Gcode()
-	fmt.Println(doc.Export(im.Precision))
+	return ImportLayers(name, file)
 }
@@ -0,0 +1,234 @@
+package main
+
+// This file contains the measures of lines, arcs and paths: lengths, points
+// and tangents at a distance along them, splits, closest points, bounds and
+// areas. Distances are measured from the start of the move, and clamped to
+// the move.
+
+import "math"
+
+// Measurable is implemented by moves that can be measured along their length
+type Measurable interface {
+	Length() float64
+	At(d float64) Vector
+	Tangent(d float64) Vector
+	Closest(p Vector) Vector
+	Bounds() Box
+}
+
+// measure returns m if it is measurable, or the line between its ends
+func measure(m Move) Measurable {
+	if mm, ok := m.(Measurable); ok {
+		return mm
+	}
+	from, to := m.Move()
+	return &Line{from, to}
+}
+
+// clamp brings d back to [0, max]
+func clamp(d, max float64) float64 {
+	return math.Max(0, math.Min(d, max))
+}
+
+func (l Line) Length() float64 {
+	return l.To.Diff(l.From).Norm()
+}
+
+// At returns the point at a distance d from the start
+func (l Line) At(d float64) Vector {
+	length := l.Length()
+	if length == 0 {
+		return l.From
+	}
+	return l.From.Sum(l.To.Diff(l.From).Multiply(clamp(d, length) / length))
+}
+
+// Tangent returns the direction of the line, as a unit vector
+func (l Line) Tangent(d float64) Vector {
+	return tangent(&l, l.From)
+}
+
+// Split cuts the line at a distance d from the start
+func (l Line) Split(d float64) (*Line, *Line) {
+	p := l.At(d)
+	return &Line{l.From, p}, &Line{p, l.To}
+}
+
+// Closest returns the point of the line closest to p
+func (l Line) Closest(p Vector) Vector {
+	d := l.To.Diff(l.From)
+	n := d.Dot(d)
+	if n == 0 {
+		return l.From
+	}
+	return l.From.Sum(d.Multiply(clamp(p.Diff(l.From).Dot(d)/n, 1)))
+}
+
+func (l Line) Bounds() Box {
+	return Box{l.From, l.From}.extend(l.To)
+}
+
+func (a Arc) Length() float64 {
+	return a.radius() * math.Abs(a.Sweep())
+}
+
+// At returns the point at a distance d from the start, along the arc
+func (a Arc) At(d float64) Vector {
+	r := a.radius()
+	if r == 0 {
+		return a.From
+	}
+	return a.at(clamp(d, a.Length()) / r)
+}
+
+// Tangent returns the direction of travel at a distance d from the start, as a
+// unit vector
+func (a Arc) Tangent(d float64) Vector {
+	return tangent(&a, a.At(d))
+}
+
+// Split cuts the arc at a distance d from the start, along the arc
+func (a Arc) Split(d float64) (*Arc, *Arc) {
+	p := a.At(d)
+	return &Arc{a.From, p, a.Center, a.CW}, &Arc{p, a.To, a.Center, a.CW}
+}
+
+// Closest returns the point of the arc closest to p
+func (a Arc) Closest(p Vector) Vector {
+	v := p.Diff(a.Center)
+	if v.Norm() > 0 && a.covers(p) {
+		return a.Center.Sum(v.Multiply(a.radius() / v.Norm()))
+	}
+	if p.Diff(a.From).Norm() <= p.Diff(a.To).Norm() {
+		return a.From
+	}
+	return a.To
+}
+
+// Bounds returns the tight bounding box of the arc, including its extremes
+func (a Arc) Bounds() Box {
+	b := Box{a.From, a.From}.extend(a.To)
+	r := a.radius()
+	for _, v := range []Vector{{r, 0, 0}, {0, r, 0}, {-r, 0, 0}, {0, -r, 0}} {
+		p := a.Center.Sum(v)
+		p.Z = a.From.Z
+		if a.covers(p) {
+			b = b.extend(p)
+		}
+	}
+	return b
+}
+
+// Bounds returns a bounding box of the spline, which stays in the convex hull
+// of its control points
+func (s Spline) Bounds() Box {
+	b := emptyBox()
+	for _, c := range s.Controls {
+		b = b.extend(c)
+	}
+	return b
+}
+
+func (p Path) Length() float64 {
+	sum := 0.0
+	for _, m := range p {
+		sum += measure(m).Length()
+	}
+	return sum
+}
+
+// locate returns the index of the move at a distance d from the start of the
+// path, and the distance from the start of that move
+func (p Path) locate(d float64) (int, float64) {
+	for i, m := range p {
+		l := measure(m).Length()
+		if d <= l || i == len(p)-1 {
+			return i, d
+		}
+		d -= l
+	}
+	return -1, 0
+}
+
+// At returns the point at a distance d from the start, along the path
+func (p Path) At(d float64) Vector {
+	i, rest := p.locate(clamp(d, p.Length()))
+	if i < 0 {
+		return Vector{}
+	}
+	return measure(p[i]).At(rest)
+}
+
+// Tangent returns the direction of travel at a distance d from the start, as a
+// unit vector
+func (p Path) Tangent(d float64) Vector {
+	i, rest := p.locate(clamp(d, p.Length()))
+	if i < 0 {
+		return Vector{}
+	}
+	return measure(p[i]).Tangent(rest)
+}
+
+// Split cuts the path at a distance d from the start. The moves of the path
+// are shared with the result, except the one that is cut.
+func (p Path) Split(d float64) (Path, Path) {
+	i, rest := p.locate(clamp(d, p.Length()))
+	if i < 0 {
+		return Path{}, Path{}
+	}
+	head, tail := append(Path{}, p[:i]...), append(Path{}, p[i+1:]...)
+	var first, second Move
+	switch m := p[i].(type) {
+	case *Line:
+		first, second = m.Split(rest)
+	case *Arc:
+		first, second = m.Split(rest)
+	default:
+		// moves that can not be cut go in the first half
+		first = m
+	}
+	if first != nil && measure(first).Length() > 0 {
+		head = append(head, first)
+	}
+	if second != nil && measure(second).Length() > 0 {
+		tail = append(Path{second}, tail...)
+	}
+	return head, tail
+}
+
+// Closest returns the point of the path closest to p
+func (p Path) Closest(v Vector) Vector {
+	best, dist := Vector{}, math.Inf(1)
+	for _, m := range p {
+		c := measure(m).Closest(v)
+		if d := c.Diff(v).Norm(); d < dist {
+			best, dist = c, d
+		}
+	}
+	return best
+}
+
+// Bounds returns the tight bounding box of the path. The box of an empty path
+// is empty.
+func (p Path) Bounds() Box {
+	b := emptyBox()
+	for _, m := range p {
+		b = b.union(bounds(m))
+	}
+	return b
+}
+
+// Area returns the signed area enclosed by a closed path, positive when the
+// path runs counter-clockwise. Arcs are taken into account.
+func (p Path) Area() float64 {
+	sum := 0.0
+	for _, m := range p {
+		from, to := m.Move()
+		sum += from.cross(to) / 2
+		if a, ok := m.(*Arc); ok {
+			r, s := a.radius(), a.Sweep()
+			sum += r * r / 2 * (s - math.Sin(s))
+		}
+	}
+	return sum
+}
@@ -0,0 +1,260 @@
+package main
+
+// This file contains the nesting of parts on a rectangular stock. A part is an
+// outer closed contour, with every path inside it. Parts are placed with a
+// bottom-left fill on a raster of the stock: each part, dilated by half the
+// spacing, is rasterized at each allowed rotation, and put at the lowest, then
+// leftmost, position where it does not overlap the parts already placed.
+
+import (
+	"math"
+	"sort"
+)
+
+// Nesting describes the stock parts are nested on
+type Nesting struct {
+	Width, Height float64 // size of the stock, from the origin
+	Spacing       float64 // minimum distance between parts, and to the edges
+	Rotations     int     // number of orientations tried, evenly spread over a turn
+	Resolution    float64 // size of the cells of the raster
+}
+
+// NestResult is the outcome of a nesting
+type NestResult struct {
+	Model       Model   // placed parts
+	Placed      int     // number of placed parts
+	Unfitted    []Model // parts that did not fit, and paths outside of any part
+	Utilisation float64 // area of the placed parts over the area of the stock
+}
+
+// span is an interval of occupied cells in a row, [From, To)
+type span struct {
+	From, To int
+}
+
+// mask is a rasterized part
+type mask struct {
+	Origin Vector // corner of the first cell
+	Width  int
+	Rows   [][]span
+}
+
+// raster is the occupancy of the stock
+type raster struct {
+	width, height int
+	cells         [][]bool
+	sums          [][]int // prefix sums of each row
+}
+
+func newRaster(width, height int) *raster {
+	r := &raster{width: width, height: height}
+	r.cells = make([][]bool, height)
+	r.sums = make([][]int, height)
+	for j := range r.cells {
+		r.cells[j] = make([]bool, width)
+		r.sums[j] = make([]int, width+1)
+	}
+	return r
+}
+
+// fill marks cells as occupied, and updates the prefix sums of the row
+func (r *raster) fill(j, from, to int) {
+	for i := from; i < to; i++ {
+		r.cells[j][i] = true
+	}
+	for i, c := range r.cells[j] {
+		r.sums[j][i+1] = r.sums[j][i]
+		if c {
+			r.sums[j][i+1]++
+		}
+	}
+}
+
+// fits tells if the mask can be put with its first cell at (x, y)
+func (r *raster) fits(m mask, x, y int) bool {
+	if x+m.Width > r.width || y+len(m.Rows) > r.height {
+		return false
+	}
+	for j, row := range m.Rows {
+		sums := r.sums[y+j]
+		for _, s := range row {
+			if sums[x+s.To]-sums[x+s.From] > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *raster) put(m mask, x, y int) {
+	for j, row := range m.Rows {
+		for _, s := range row {
+			r.fill(y+j, x+s.From, x+s.To)
+		}
+	}
+}
+
+// search returns the lowest, then leftmost, position where the mask fits
+func (r *raster) search(m mask) (int, int, bool) {
+	for y := 0; y+len(m.Rows) <= r.height; y++ {
+		for x := 0; x+m.Width <= r.width; x++ {
+			if r.fits(m, x, y) {
+				return x, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// rasterize returns the cells within d of the part
+func (n Nesting) rasterize(part Model, d float64) mask {
+	b := part.Bounds()
+	res := n.Resolution
+	m := mask{Origin: b.Min.Diff(Vector{d, d, 0})}
+	m.Width = int(math.Ceil((b.Max.X - b.Min.X + 2*d) / res))
+	height := int(math.Ceil((b.Max.Y - b.Min.Y + 2*d) / res))
+	region := []Path{part[0]}
+	// a cell is occupied if it may contain a point within d of the part
+	reach := d + res*math.Sqrt2/2
+	for j := 0; j < height; j++ {
+		row := []span{}
+		for i := 0; i < m.Width; i++ {
+			c := m.Origin.Sum(Vector{(float64(i) + 0.5) * res, (float64(j) + 0.5) * res, 0})
+			in := winding(region, c) != 0
+			for _, p := range part {
+				for _, mo := range p {
+					in = in || distance(mo, c) <= reach
+				}
+			}
+			if !in {
+				continue
+			}
+			if k := len(row) - 1; k >= 0 && row[k].To == i {
+				row[k].To++
+			} else {
+				row = append(row, span{i, i + 1})
+			}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
+
+// parts splits the model in parts: outer closed contours, first in each part,
+// with the paths inside them. Paths outside of any closed contour are returned
+// apart.
+func parts(m Model) ([]Model, []Path) {
+	closed := func(p Path) bool {
+		return len(p) > 0 && p.IsClosed() && math.Abs(p.Area()) > TOLERANCE
+	}
+	ps := []Model{}
+	outer := make([]bool, len(m))
+	for i, p := range m {
+		if !closed(p) {
+			continue
+		}
+		outer[i] = true
+		for j, q := range m {
+			if i != j && closed(q) && contains(q, math.Abs(q.Area()), p) {
+				outer[i] = false
+				break
+			}
+		}
+		if outer[i] {
+			ps = append(ps, Model{p})
+		}
+	}
+
+	loose := []Path{}
+	for i, p := range m {
+		if len(p) == 0 || outer[i] {
+			continue
+		}
+		owner := -1
+		for k, part := range ps {
+			if contains(part[0], math.Abs(part[0].Area()), p) {
+				owner = k
+				break
+			}
+		}
+		if owner < 0 {
+			loose = append(loose, p)
+		} else {
+			ps[owner] = append(ps[owner], p)
+		}
+	}
+	return ps, loose
+}
+
+// Nest places the parts of the model on the stock, biggest parts first
+func (n Nesting) Nest(m Model) NestResult {
+	if n.Rotations < 1 {
+		n.Rotations = 1
+	}
+	if n.Resolution <= 0 {
+		n.Resolution = 1
+	}
+	ps, loose := parts(m)
+	order := make([]int, len(ps))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return math.Abs(ps[order[i]][0].Area()) > math.Abs(ps[order[j]][0].Area())
+	})
+
+	res := NestResult{Model: Model{}}
+	r := newRaster(int(n.Width/n.Resolution), int(n.Height/n.Resolution))
+	// the edges of the stock are kept clear, like another part
+	edge := int(math.Min(math.Ceil(n.Spacing/2/n.Resolution), float64(r.width)))
+	for j := 0; j < r.height; j++ {
+		if j < edge || j >= r.height-edge {
+			r.fill(j, 0, r.width)
+			continue
+		}
+		r.fill(j, 0, edge)
+		r.fill(j, r.width-edge, r.width)
+	}
+
+	used := 0.0
+	for _, k := range order {
+		best := struct {
+			part Model
+			mask mask
+			x, y int
+			ok   bool
+		}{}
+		for rot := 0; rot < n.Rotations; rot++ {
+			t := Rotation(2 * math.Pi * float64(rot) / float64(n.Rotations))
+			part := ps[k].Clone()
+			part.Transform(t)
+			mk := n.rasterize(part, n.Spacing/2)
+			x, y, ok := r.search(mk)
+			if !ok {
+				continue
+			}
+			// lowest top, then leftmost
+			if !best.ok || y+len(mk.Rows) < best.y+len(best.mask.Rows) ||
+				(y+len(mk.Rows) == best.y+len(best.mask.Rows) && x < best.x) {
+				best.part, best.mask, best.x, best.y, best.ok = part, mk, x, y, true
+			}
+		}
+		if !best.ok {
+			res.Unfitted = append(res.Unfitted, ps[k])
+			continue
+		}
+		r.put(best.mask, best.x, best.y)
+		corner := Vector{float64(best.x) * n.Resolution, float64(best.y) * n.Resolution, 0}
+		best.part.Transform(Translation(corner.Diff(best.mask.Origin)))
+		res.Model = append(res.Model, best.part...)
+		res.Placed++
+		used += math.Abs(ps[k][0].Area())
+	}
+	for _, p := range loose {
+		res.Unfitted = append(res.Unfitted, Model{p})
+	}
+	if n.Width > 0 && n.Height > 0 {
+		res.Utilisation = used / (n.Width * n.Height)
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParts(t *testing.T) {
+	m := Model{
+		square(0, 0, 10),
+		square(2, 2, 2),
+		Path{&Line{Vector{5, 5, 0}, Vector{6, 6, 0}}},
+		square(20, 0, 5),
+		Path{&Line{Vector{50, 50, 0}, Vector{60, 60, 0}}},
+	}
+	ps, loose := parts(m)
+	assert.Len(t, ps, 2)
+	assert.Len(t, ps[0], 3, "the hole and the engraving belong to the first part")
+	assert.Len(t, ps[1], 1)
+	assert.Len(t, loose, 1)
+}
+
+func TestNest(t *testing.T) {
+	// a rectangle only fits the stock once rotated
+	m := Model{
+		polygon(Vector{0, 0, 0}, Vector{10, 0, 0}, Vector{10, 40, 0}, Vector{0, 40, 0}),
+		square(100, 100, 10),
+		square(200, 200, 10),
+		square(300, 300, 30),
+	}
+	n := Nesting{Width: 50, Height: 30, Spacing: 2, Rotations: 4, Resolution: 0.5}
+	res := n.Nest(m)
+	assert.Equal(t, 3, res.Placed)
+	assert.Len(t, res.Unfitted, 1, "the big square does not fit")
+	assert.InDelta(t, 600.0/1500, res.Utilisation, 1e-9)
+
+	// parts are on the stock, and do not overlap
+	b := res.Model.Bounds()
+	assert.False(t, b.Empty())
+	assert.True(t, b.Min.X >= 1 && b.Min.Y >= 1)
+	assert.True(t, b.Max.X <= 49 && b.Max.Y <= 29)
+	for i, p := range res.Model {
+		for _, q := range res.Model[i+1:] {
+			assert.False(t, p.Bounds().overlaps(q.Bounds()))
+		}
+	}
+}
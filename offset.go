@@ -0,0 +1,114 @@
+package main
+
+// This file contains the offsetting of regions. Growing a region by a radius r
+// is the same as adding to it the area swept by a disc of radius r following
+// its boundary, so the offset is computed as the union of the region and of
+// the strokes of all its moves. Shrinking it removes the strokes instead.
+
+import "math"
+
+// circle returns a closed path running counter-clockwise around center
+func circle(center Vector, radius float64) Path {
+	a := center.Sum(Vector{radius, 0, 0})
+	b := center.Sum(Vector{-radius, 0, 0})
+	return Path{
+		&Arc{a, b, center, false},
+		&Arc{b, a, center, false},
+	}
+}
+
+// polygon returns a closed path made of lines going through the points
+func polygon(pts ...Vector) Path {
+	p := make(Path, len(pts))
+	for i, from := range pts {
+		p[i] = &Line{from, pts[(i+1)%len(pts)]}
+	}
+	return p
+}
+
+// counterClockwise reverses a closed path if it runs clockwise
+func counterClockwise(p Path) Path {
+	if p.Area() < 0 {
+		p.Reverse()
+	}
+	return p
+}
+
+// stadium returns the region covered by a disc of the given radius moving
+// along a straight line from a to b.
+func stadium(a, b Vector, radius float64) Path {
+	d := b.Diff(a)
+	if d.Norm() <= TOLERANCE {
+		return circle(a, radius)
+	}
+	n := Vector{-d.Y, d.X, 0}.Multiply(radius / d.Norm())
+	return Path{
+		&Line{a.Diff(n), b.Diff(n)},
+		&Arc{b.Diff(n), b.Sum(n), b, false},
+		&Line{b.Sum(n), a.Sum(n)},
+		&Arc{a.Sum(n), a.Diff(n), a, false},
+	}
+}
+
+// stroke returns the regions covered by a disc of the given radius moving
+// along a move. Their union is the actual stroke.
+func stroke(m Move, radius float64) [][]Path {
+	switch m := m.(type) {
+	case *Arc:
+		r := m.radius()
+		out := func(p Vector) Vector {
+			return m.Center.Sum(p.Diff(m.Center).Multiply((r + radius) / r))
+		}
+		var sector Path
+		if radius < r {
+			in := func(p Vector) Vector {
+				return m.Center.Sum(p.Diff(m.Center).Multiply((r - radius) / r))
+			}
+			sector = Path{
+				&Arc{out(m.From), out(m.To), m.Center, m.CW},
+				&Line{out(m.To), in(m.To)},
+				&Arc{in(m.To), in(m.From), m.Center, !m.CW},
+				&Line{in(m.From), out(m.From)},
+			}
+		} else {
+			// the stroke covers the center of the arc
+			sector = Path{
+				&Arc{out(m.From), out(m.To), m.Center, m.CW},
+				&Line{out(m.To), m.Center},
+				&Line{m.Center, out(m.From)},
+			}
+		}
+		return [][]Path{
+			{counterClockwise(sector)},
+			{circle(m.From, radius)},
+			{circle(m.To, radius)},
+		}
+	default:
+		from, to := m.Move()
+		return [][]Path{{stadium(from, to, radius)}}
+	}
+}
+
+// Offset grows a region by radius, or shrinks it if radius is negative, and
+// returns the boundaries of the result. The region is made of closed paths,
+// see orient.
+func Offset(region []Path, radius float64) []Path {
+	region = orient(region)
+	if radius == 0 || len(region) == 0 {
+		return region
+	}
+	strokes := [][]Path{}
+	for _, p := range region {
+		for _, m := range p {
+			from, to := m.Move()
+			if _, ok := m.(*Arc); !ok && from.near(to) {
+				continue
+			}
+			strokes = append(strokes, stroke(m, math.Abs(radius))...)
+		}
+	}
+	if radius < 0 {
+		return difference(region, unionAll(strokes))
+	}
+	return unionAll(append([][]Path{region}, strokes...))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func square(x, y, size float64) Path {
+	return polygon(Vector{x, y, 0}, Vector{x + size, y, 0}, Vector{x + size, y + size, 0}, Vector{x, y + size, 0})
+}
+
+func totalArea(paths []Path) float64 {
+	sum := 0.0
+	for _, p := range paths {
+		sum += p.Area()
+	}
+	return sum
+}
+
+func TestUnionOverlapping(t *testing.T) {
+	res := union([]Path{square(0, 0, 2)}, []Path{square(1, 1, 2)})
+	assert.Len(t, res, 1, "overlapping squares should merge")
+	assert.InDelta(t, 7, totalArea(res), 1e-9)
+	assert.True(t, res[0].IsClosed())
+}
+
+func TestUnionDisjoint(t *testing.T) {
+	res := union([]Path{square(0, 0, 1)}, []Path{square(2, 0, 1)})
+	assert.Len(t, res, 2, "disjoint squares should stay apart")
+	assert.InDelta(t, 2, totalArea(res), 1e-9)
+}
+
+func TestUnionSharedEdge(t *testing.T) {
+	res := union([]Path{square(0, 0, 1)}, []Path{square(1, 0, 1)})
+	assert.Len(t, res, 1, "squares sharing an edge should merge")
+	assert.InDelta(t, 2, totalArea(res), 1e-9)
+}
+
+func TestUnionCircles(t *testing.T) {
+	res := union([]Path{circle(Vector{0, 0, 0}, 1)}, []Path{circle(Vector{1, 0, 0}, 1)})
+	assert.Len(t, res, 1)
+	// two discs minus their lens
+	lens := 2*math.Acos(0.5) - 0.5*math.Sqrt(3)
+	assert.InDelta(t, 2*math.Pi-lens, totalArea(res), 1e-9)
+}
+
+func TestUnionHole(t *testing.T) {
+	// a frame made of four bars leaves a hole in the middle
+	bars := [][]Path{
+		{polygon(Vector{0, 0, 0}, Vector{3, 0, 0}, Vector{3, 1, 0}, Vector{0, 1, 0})},
+		{polygon(Vector{0, 2, 0}, Vector{3, 2, 0}, Vector{3, 3, 0}, Vector{0, 3, 0})},
+		{polygon(Vector{0, 0, 0}, Vector{1, 0, 0}, Vector{1, 3, 0}, Vector{0, 3, 0})},
+		{polygon(Vector{2, 0, 0}, Vector{3, 0, 0}, Vector{3, 3, 0}, Vector{2, 3, 0})},
+	}
+	res := unionAll(bars)
+	assert.Len(t, res, 2, "expected an outline and a hole")
+	assert.InDelta(t, 8, totalArea(res), 1e-9)
+}
+
+func TestOffsetSquare(t *testing.T) {
+	res := Offset([]Path{square(0, 0, 2)}, 0.5)
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 4+4*2*0.5+math.Pi*0.25, totalArea(res), 1e-9)
+}
+
+func TestOffsetHole(t *testing.T) {
+	// the hole shrinks while the outline grows
+	res := Offset([]Path{square(0, 0, 10), square(4, 4, 2)}, 0.5)
+	assert.Len(t, res, 2)
+	outer := 100 + 4*10*0.5 + math.Pi*0.25
+	hole := (2 - 1) * (2 - 1)
+	assert.InDelta(t, outer-float64(hole), totalArea(res), 1e-9)
+}
+
+func TestInset(t *testing.T) {
+	res := Offset([]Path{square(0, 0, 4)}, -0.5)
+	assert.Len(t, res, 1)
+	assert.InDelta(t, 9, totalArea(res), 1e-9)
+
+	// the hole grows while the outline shrinks
+	res = Offset([]Path{square(0, 0, 10), square(4, 4, 2)}, -0.5)
+	assert.Len(t, res, 2)
+	hole := 4 + 4*2*0.5 + math.Pi*0.25
+	assert.InDelta(t, 81-hole, totalArea(res), 1e-9)
+
+	// thin parts vanish
+	assert.Len(t, Offset([]Path{square(0, 0, 1)}, -0.6), 0)
+}
@@ -0,0 +1,320 @@
+package main
+
+// This file contains the operations, that turn a model into passes of the tool
+// at given depths.
+
+import (
+	"fmt"
+	"math"
+)
+
+// Kinds of operations
+const (
+	Engrave  = "engrave" // follow the paths
+	Profile  = "profile" // go around closed paths, on the outside
+	Drilling = "drill"   // plunge at points and at the center of circles
+	VCarving = "vcarve"  // follow the medial axis of closed paths with a V bit
+	Pocket   = "pocket"  // clear the inside of closed paths
+	Rest     = "rest"    // clear what a larger tool left in pockets
+)
+
+// Operation describes how a model is machined
+type Operation struct {
+	Name       string
+	Kind       string
+	Tool       float64 // diameter of the tool
+	ToolNumber int     // number of the tool in the library, 0 if unknown
+	Depth      float64 // final depth, positive below the surface
+	PassDepth  float64 // maximum depth of a pass, 0 to cut in a single pass
+	Feed       float64 // feed rate of cutting moves, 0 to leave it unset
+	PlungeFeed float64 // feed rate of plunges, 0 to use Feed
+	Speed      float64 // spindle speed, 0 to leave it unset
+	Dwell      float64 // pause before each plunge, in seconds
+	Optimize   bool    // order the paths, see Order
+	Angle      float64 // included angle of V bits, in degrees
+	StepOver   float64 // distance between the passes clearing areas: half the tool if 0 for pockets, no clearing if 0 for v-carvings
+	Previous   float64 // diameter of the larger tool that cleared the pockets before, for rest machining
+	Tolerance  float64 // distance between the samples of the boundaries of v-carvings, 0.1 if 0
+	Tabs       Tabs    // tabs left by profiles
+}
+
+// Tabs describes the bridges holding the parts cut out by profiles
+type Tabs struct {
+	Count  int     `json:"count"`  // number of tabs along each closed path, 0 for none
+	Width  float64 `json:"width"`  // width of the tabs
+	Height float64 `json:"height"` // height of the tabs, from the final depth
+}
+
+// apply returns the path going over the tabs, for a pass at the given depth
+// of an operation of final depth final. The width of the tabs is measured
+// between the edges of the tool.
+func (t Tabs) apply(p Path, depth, final, tool float64) Path {
+	lift := depth - (final - t.Height)
+	if t.Count <= 0 || lift <= 0 || !p.IsClosed() {
+		return p
+	}
+	length := p.Length()
+	span := t.Width + tool
+	if span*float64(t.Count) >= length {
+		Log.Println("No room for tabs on", p)
+		return p
+	}
+
+	res := Path{}
+	rest := p
+	pos := 0.0
+	for k := 0; k < t.Count; k++ {
+		start := length*(float64(k)+0.5)/float64(t.Count) - span/2
+		head, tail := rest.Split(start - pos)
+		tab, tail := tail.Split(span)
+		res = append(res, head...)
+
+		// go up, over the tab, and down again
+		from, to := tab.Move()
+		top := from
+		top.Z += lift
+		res = append(res, &Line{from, top})
+		for _, m := range tab.Clone() {
+			relative(m, lift)
+			res = append(res, m)
+		}
+		top = to
+		top.Z += lift
+		res = append(res, &Line{top, to})
+
+		rest = tail
+		pos = start + span
+	}
+	return append(res, rest...)
+}
+
+// pass returns a pass of the operation
+func (op Operation) pass(p Path, depth float64) Pass {
+	return Pass{
+		Operation:  op.Name,
+		Depth:      depth,
+		Feed:       op.Feed,
+		PlungeFeed: op.PlungeFeed,
+		Speed:      op.Speed,
+		Tool:       op.ToolNumber,
+		Dwell:      op.Dwell,
+		Path:       p,
+	}
+}
+
+// closedPaths separates the closed paths of the model from the open ones
+func closedPaths(m Model) (closed, open []Path) {
+	for _, p := range m {
+		if p.IsClosed() {
+			closed = append(closed, p)
+		} else {
+			Log.Println("Engraving open path", p)
+			open = append(open, p)
+		}
+	}
+	return closed, open
+}
+
+// depths returns the depth of each pass, down to the final depth
+func (op Operation) depths() []float64 {
+	if op.PassDepth <= 0 || op.PassDepth >= op.Depth {
+		return []float64{op.Depth}
+	}
+	ds := []float64{}
+	for d := op.PassDepth; d < op.Depth-EPSILON; d += op.PassDepth {
+		ds = append(ds, d)
+	}
+	return append(ds, op.Depth)
+}
+
+// Passes returns the passes needed to machine the model. Each path is machined
+// down to the final depth before moving to the next one.
+func (op Operation) Passes(m Model) ([]Pass, error) {
+	var paths []Path
+	switch op.Kind {
+	case Engrave, "":
+		paths = m
+	case Profile:
+		closed, open := closedPaths(m)
+		paths = append(open, Offset(closed, op.Tool/2)...)
+	case Drilling:
+		for _, p := range m {
+			d, err := op.hole(p)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, Path{d})
+		}
+		// holes are drilled in a single pass
+		op.PassDepth = 0
+	case VCarving:
+		return op.vcarve(m), nil
+	case Pocket:
+		return op.pocket(m), nil
+	case Rest:
+		return op.rest(m)
+	default:
+		return nil, fmt.Errorf("unknown operation %s", op.Kind)
+	}
+
+	if op.Optimize {
+		paths = Order(paths)
+	}
+
+	passes := []Pass{}
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
+		}
+		for _, d := range op.depths() {
+			path := p
+			if op.Kind == Profile {
+				path = op.Tabs.apply(p, d, op.Depth, op.Tool)
+			}
+			passes = append(passes, op.pass(path, d))
+		}
+	}
+	return passes, nil
+}
+
+// hole returns the hole drilled for a path: a drill keeps its diameter, and a
+// point or a circle is drilled with the tool, at its centre. Other paths are
+// refused.
+func (op Operation) hole(p Path) (*Drill, error) {
+	if len(p) == 1 {
+		if h, ok := p[0].(*Drill); ok {
+			return &Drill{At: h.At, Diameter: h.Diameter}, nil
+		}
+	}
+	from, _ := p.Move()
+	if len(p) > 0 && p.Length() < EPSILON {
+		return &Drill{At: from, Diameter: op.Tool}, nil
+	}
+	if center, ok := p.center(); ok {
+		return &Drill{At: center, Diameter: op.Tool}, nil
+	}
+	return nil, fmt.Errorf("can not drill the path from %v, it is neither a point nor a circle", from)
+}
+
+// center returns the center of a circle, made of arcs around the same point
+// closing the path
+func (p Path) center() (Vector, bool) {
+	if len(p) == 0 || !p.IsClosed() {
+		return Vector{}, false
+	}
+	first, ok := p[0].(*Arc)
+	if !ok {
+		return Vector{}, false
+	}
+	for _, m := range p[1:] {
+		a, ok := m.(*Arc)
+		if !ok || a.Center.Diff(first.Center).Norm() > EPSILON {
+			return Vector{}, false
+		}
+	}
+	return first.Center, true
+}
+
+// vcarve returns the passes carving the closed paths of the model, the depth
+// being limited to the final depth. Open paths are engraved.
+func (op Operation) vcarve(m Model) []Pass {
+	closed, open := closedPaths(m)
+	v := VCarve{
+		Angle:     op.Angle,
+		MaxDepth:  op.Depth,
+		Spacing:   op.Tolerance,
+		ClearStep: op.StepOver,
+	}
+	if v.Spacing <= 0 {
+		v.Spacing = 0.1
+	}
+	clearing := v.Clearing(closed)
+	carving := v.Paths(closed)
+	if op.Optimize {
+		clearing = Order(clearing)
+		carving = Order(carving)
+		open = Order(open)
+	}
+	passes := []Pass{}
+	for _, p := range clearing {
+		passes = append(passes, op.pass(p, op.Depth))
+	}
+	// the depth of carving paths is given by the height of their moves
+	for _, p := range carving {
+		passes = append(passes, op.pass(p, 0))
+	}
+	for _, p := range open {
+		passes = append(passes, op.pass(p, op.Depth))
+	}
+	return passes
+}
+
+// pocket returns the passes clearing the inside of the closed paths, with
+// rings getting smaller by the step over. Each level is cleared before going
+// deeper. Open paths are engraved.
+func (op Operation) pocket(m Model) []Pass {
+	closed, open := closedPaths(m)
+	step := op.StepOver
+	if step <= 0 {
+		step = op.Tool / 2
+	}
+	rings := []Path{}
+	for inset := op.Tool / 2; ; inset += step {
+		ps := Offset(closed, -inset)
+		if len(ps) == 0 {
+			break
+		}
+		rings = append(rings, ps...)
+	}
+	if op.Optimize {
+		rings = Order(rings)
+		open = Order(open)
+	}
+
+	passes := []Pass{}
+	for _, d := range op.depths() {
+		for _, p := range rings {
+			passes = append(passes, op.pass(p, d))
+		}
+	}
+	for _, p := range open {
+		for _, d := range op.depths() {
+			passes = append(passes, op.pass(p, d))
+		}
+	}
+	return passes
+}
+
+// unreached returns the parts of a region that a tool of the given diameter
+// can not reach: the region minus the area swept by the tool when its center
+// goes everywhere it fits.
+func unreached(region []Path, tool float64) []Path {
+	swept := Offset(Offset(region, -tool/2), tool/2)
+	res := []Path{}
+	for _, p := range Difference(region, swept) {
+		if math.Abs(p.Area()) > TOLERANCE {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// rest returns the passes clearing, with the tool, what the previous tool left
+// in the pockets of the closed paths. The area pocketed goes a tool diameter
+// beyond what was left, so the tool can get to it. Open paths are ignored.
+func (op Operation) rest(m Model) ([]Pass, error) {
+	if op.Previous <= op.Tool {
+		return nil, fmt.Errorf("rest machining with a tool of %g needs a larger previous tool, not %g", op.Tool, op.Previous)
+	}
+	closed := []Path{}
+	for _, p := range m {
+		if p.IsClosed() {
+			closed = append(closed, p)
+		}
+	}
+	left := unreached(closed, op.Previous)
+	if len(left) == 0 {
+		return nil, nil
+	}
+	return op.pocket(Model(Intersection(closed, Offset(left, op.Tool)))), nil
+}
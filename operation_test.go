@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPocket(t *testing.T) {
+	op := Operation{Kind: Pocket, Tool: 2, Depth: 2, PassDepth: 1, StepOver: 1}
+	passes, err := op.Passes(Model{square(0, 0, 10)})
+	assert.NoError(t, err)
+	// rings inset by 1, 2, 3 and 4, at each level
+	assert.Len(t, passes, 8)
+	for i, p := range passes[:4] {
+		assert.Equal(t, 1.0, p.Depth, "the first level is cleared before going deeper")
+		b := p.Path.Bounds()
+		assert.InDelta(t, float64(i+1), b.Min.X, 1e-6)
+	}
+	assert.Equal(t, 2.0, passes[4].Depth)
+}
+
+func TestTabs(t *testing.T) {
+	op := Operation{Kind: Profile, Tool: 2, Depth: 3, PassDepth: 1,
+		Tabs: Tabs{Count: 2, Width: 4, Height: 1.5}}
+	passes, err := op.Passes(Model{square(0, 0, 20)})
+	assert.NoError(t, err)
+	assert.Len(t, passes, 3)
+	// the first pass is above the tabs
+	assert.Len(t, passes[0].Path, 8)
+
+	for _, p := range passes[1:] {
+		lift := p.Depth - 1.5
+		up, over := 0, 0.0
+		for _, m := range p.Path {
+			from, to := m.Move()
+			if from.near(to) && to.Z > from.Z {
+				up++
+				assert.InDelta(t, lift, to.Z, 1e-9)
+			}
+			if from.Z > 0 && to.Z > 0 {
+				over += measure(m).Length()
+			}
+		}
+		assert.Equal(t, 2, up)
+		// tabs are 4 wide, the tool goes over them on 4 + 2
+		assert.InDelta(t, 12, over, 1e-6)
+		assert.True(t, p.Path.IsClosed())
+	}
+}
+
+func TestRest(t *testing.T) {
+	region := Model{square(0, 0, 20), counterClockwise(circle(Vector{10, 10, 0}, 3))}
+	left := unreached(region, 6)
+	// a 6 mm tool leaves the corners of the square, and goes all around the
+	// island
+	assert.Len(t, left, 4)
+	for _, p := range left {
+		assert.InDelta(t, 9-9*math.Pi/4, math.Abs(p.Area()), 1e-6)
+	}
+
+	op := Operation{Kind: Rest, Tool: 2, Previous: 6, Depth: 1}
+	passes, err := op.Passes(region)
+	assert.NoError(t, err)
+	assert.Len(t, passes, 4)
+	for _, p := range passes {
+		b := p.Path.Bounds()
+		// the passes stay in the corners, and reach as far as the tool can
+		near := math.Min(b.Min.X, 20-b.Max.X)
+		assert.InDelta(t, 1, near, 1e-6)
+		assert.True(t, b.Max.X-b.Min.X < 3, "%v away from the corners", b)
+	}
+
+	op.Previous = 2
+	_, err = op.Passes(region)
+	assert.Error(t, err, "the previous tool must be larger")
+
+	// nothing left by a tool small enough for the region
+	op.Previous = 3
+	passes, err = op.Passes(Model{circle(Vector{0, 0, 0}, 5)})
+	assert.NoError(t, err)
+	assert.Empty(t, passes)
+}
+
+func TestDrilling(t *testing.T) {
+	op := Operation{Kind: Drilling, Tool: 3, Depth: 4}
+	passes, err := op.Passes(Model{
+		Path{&Drill{Vector{1, 1, 0}, 5}},
+		Path{&Line{Vector{2, 2, 0}, Vector{2, 2, 0}}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, passes, 2)
+	assert.Equal(t, &Drill{Vector{1, 1, 0}, 5}, passes[0].Path[0], "drills keep their diameter")
+	assert.Equal(t, &Drill{Vector{2, 2, 0}, 3}, passes[1].Path[0])
+
+	_, err = op.Passes(Model{square(0, 0, 10)})
+	assert.Error(t, err, "only points and circles are drilled")
+}
@@ -0,0 +1,92 @@
+package main
+
+// This file contains the ordering of paths before machining.
+
+import "math"
+
+// contains tells if the closed path p, of absolute area a, contains q
+func contains(p Path, a float64, q Path) bool {
+	if q.IsClosed() && math.Abs(q.Area()) >= a-TOLERANCE {
+		return false
+	}
+	start, _ := q.Move()
+	return winding([]Path{p}, start) != 0
+}
+
+// entry returns the index of the move where the path should be entered to be
+// closest to pos, and the distance to it. Open paths can be entered from their
+// end (index len(p)), closed paths from any of their moves.
+func entry(p Path, pos Vector) (int, float64) {
+	from, to := p.Move()
+	best, dist := 0, from.Diff(pos).Norm()
+	if !p.IsClosed() {
+		if d := to.Diff(pos).Norm(); d < dist {
+			best, dist = len(p), d
+		}
+		return best, dist
+	}
+	for i, m := range p {
+		start, _ := m.Move()
+		if d := start.Diff(pos).Norm(); d < dist {
+			best, dist = i, d
+		}
+	}
+	return best, dist
+}
+
+// Order sorts paths to limit the travel between them: starting from the
+// origin, the nearest path is machined next. Paths inside a closed path are
+// machined before it, so a part is not cut free before its holes. Open paths
+// may be reversed, and closed paths may start at any of their points. The
+// original paths are not modified.
+func Order(paths []Path) []Path {
+	n := len(paths)
+	pending := make([]int, n) // number of paths inside i left to machine
+	outer := make([][]int, n) // paths containing i
+	for i, p := range paths {
+		if len(p) == 0 || !p.IsClosed() {
+			continue
+		}
+		a := math.Abs(p.Area())
+		if a < TOLERANCE {
+			continue
+		}
+		for j, q := range paths {
+			if i != j && len(q) > 0 && contains(p, a, q) {
+				pending[i]++
+				outer[j] = append(outer[j], i)
+			}
+		}
+	}
+
+	done := make([]bool, n)
+	res := make([]Path, 0, n)
+	var pos Vector
+	for len(res) < n {
+		best, index, dist := -1, 0, math.Inf(1)
+		for i, p := range paths {
+			if done[i] || pending[i] > 0 {
+				continue
+			}
+			if k, d := entry(p, pos); d < dist {
+				best, index, dist = i, k, d
+			}
+		}
+
+		p := paths[best]
+		switch {
+		case index == len(p) && len(p) > 0:
+			p = p.Clone()
+			p.Reverse()
+		case index > 0:
+			p = append(append(Path{}, p[index:]...), p[:index]...)
+		}
+		res = append(res, p)
+		_, pos = p.Move()
+		done[best] = true
+		for _, o := range outer[best] {
+			pending[o]--
+		}
+	}
+	return res
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderContainment(t *testing.T) {
+	outer := square(0, 0, 10)
+	hole := square(4, 4, 2)
+	far := square(20, 0, 2)
+	res := Order([]Path{outer, far, hole})
+	assert.Len(t, res, 3)
+	assert.True(t, res[0].Equal(hole), "holes are cut before the part")
+	assert.True(t, res[1].Equal(outer))
+	assert.True(t, res[2].Equal(far))
+}
+
+func TestOrderNearest(t *testing.T) {
+	a := Path{&Line{Vector{10, 0, 0}, Vector{20, 0, 0}}}
+	b := Path{&Line{Vector{5, 0, 0}, Vector{1, 0, 0}}}
+	c := polygon(Vector{35, 5, 0}, Vector{30, 5, 0}, Vector{30, 0, 0}, Vector{35, 0, 0})
+	res := Order([]Path{a, b, c})
+
+	// b is reversed, to start near the origin
+	from, to := res[0].Move()
+	assert.Equal(t, Vector{1, 0, 0}, from)
+	assert.Equal(t, Vector{5, 0, 0}, to)
+	assert.True(t, res[1].Equal(a))
+	// the closed path starts at its nearest corner
+	from, _ = res[2].Move()
+	assert.Equal(t, Vector{30, 0, 0}, from)
+
+	// the original paths are untouched
+	from, _ = b.Move()
+	assert.Equal(t, Vector{5, 0, 0}, from)
+}
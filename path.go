@@ -19,6 +19,11 @@ type Move interface {
 	Equal(Move) bool
 }
 
+// Transformer is implemented by the moves that can be transformed
+type Transformer interface {
+	Transform(Transform)
+}
+
 // Move returns the start and end points of the path
 func (p Path) Move() (Vector, Vector) {
 	if len(p) == 0 {
@@ -61,6 +66,41 @@ func (p Path) Equal(m Move) bool {
 	return true
 }
 
+// clone returns a deep copy of a move
+func clone(m Move) Move {
+	switch m := m.(type) {
+	case *Line:
+		l := *m
+		return &l
+	case *Arc:
+		a := *m
+		return &a
+	case *Drill:
+		d := *m
+		return &d
+	case *Spline:
+		s := *m
+		s.Knots = append([]float64{}, m.Knots...)
+		s.Controls = append([]Vector{}, m.Controls...)
+		s.Weights = append([]float64{}, m.Weights...)
+		return &s
+	case Path:
+		return m.Clone()
+	default:
+		return m
+	}
+}
+
+// Clone returns a deep copy of the path, so it can be modified without
+// affecting the original.
+func (p Path) Clone() Path {
+	c := make(Path, len(p))
+	for i, m := range p {
+		c[i] = clone(m)
+	}
+	return c
+}
+
 func (p Path) Points() []Vector {
 	pts := make([]Vector, 0, len(p)+1)
 	for i, m := range p {
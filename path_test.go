@@ -1,16 +1,17 @@
 package main
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-var a, b, c, d = Vector{0, 0}, Vector{1, 0}, Vector{2, 0}, Vector{3, 0}
-var e = Vector{1, 1}
+var a, b, c, d = Vector{0, 0, 0}, Vector{1, 0, 0}, Vector{2, 0, 0}, Vector{3, 0, 0}
+var e = Vector{1, 1, 0}
 var ab, bc, cd = &Line{a, b}, &Line{b, c}, &Line{c, d}
 var ba, cb, dc = &Line{b, a}, &Line{c, b}, &Line{d, c}
-var c2 = c.Sum(Vector{EPSILON / 2, 0})
+var c2 = c.Sum(Vector{EPSILON / 2, 0, 0})
 
 func path(points ...Vector) *Path {
 	p := Path{}
@@ -148,3 +149,37 @@ func TestIsCounterClockwise(t *testing.T) {
 // 		}
 // 	}
 // }
+
+func TestPathMeasures(t *testing.T) {
+	// a line, then a half circle going up and back
+	p := Path{
+		&Line{Vector{0, 0, 0}, Vector{2, 0, 0}},
+		&Arc{Vector{2, 0, 0}, Vector{2, 2, 0}, Vector{2, 1, 0}, false},
+	}
+	assert.InDelta(t, 2+math.Pi, p.Length(), 1e-9)
+	assertNear(t, Vector{1, 0, 0}, p.At(1))
+	assertNear(t, Vector{3, 1, 0}, p.At(2+math.Pi/2))
+	assertNear(t, Vector{0, 1, 0}, p.Tangent(2+math.Pi/2))
+
+	head, tail := p.Split(2 + math.Pi/2)
+	assert.Len(t, head, 2)
+	assert.Len(t, tail, 1)
+	assert.InDelta(t, 2+math.Pi/2, head.Length(), 1e-9)
+	_, end := head.Move()
+	start, _ := tail.Move()
+	assert.Equal(t, end, start)
+
+	head, tail = p.Split(2)
+	assert.Len(t, head, 1)
+	assert.Len(t, tail, 1)
+
+	assertNear(t, Vector{3, 1, 0}, p.Closest(Vector{5, 1, 0}))
+	b := p.Bounds()
+	assertNear(t, Vector{0, 0, 0}, b.Min)
+	assertNear(t, Vector{3, 2, 0}, b.Max)
+
+	sq := *path(Vector{0, 0, 0}, Vector{2, 0, 0}, Vector{2, 2, 0}, Vector{0, 2, 0}, Vector{0, 0, 0})
+	assert.InDelta(t, 4, sq.Area(), 1e-9)
+	sq.Reverse()
+	assert.InDelta(t, -4, sq.Area(), 1e-9)
+}
@@ -36,7 +36,7 @@ func (l Line) String() string {
 func (l Line) Gcode() gcode.Block {
 	g := &gcode.Block{}
 	g.AppendNode(word('G', 1))
-	g.AppendNodes(xy(l.To)...)
+	g.AppendNodes(xyz(l.To)...)
 	return *g
 }
 
@@ -82,7 +82,7 @@ func (a Arc) Gcode() gcode.Block {
 	}
 
 	// arc's endpoint
-	b.AppendNodes(xy(a.To)...)
+	b.AppendNodes(xyz(a.To)...)
 	// center (relative to the start)
 	center := a.Center.Diff(a.From)
 	b.AppendNodes(ij(center)...)
@@ -126,9 +126,53 @@ func (s *Spline) Reverse() {
 }
 
 func (s Spline) Equal(m Move) bool {
-	// FIXME
-	if _, ok := m.(*Spline); ok {
-		return true
+	o, ok := m.(*Spline)
+	if !ok || s.Degree != o.Degree || s.Closed != o.Closed ||
+		len(s.Knots) != len(o.Knots) ||
+		len(s.Controls) != len(o.Controls) ||
+		len(s.Weights) != len(o.Weights) {
+		return false
+	}
+	for i := range s.Knots {
+		if s.Knots[i] != o.Knots[i] {
+			return false
+		}
+	}
+	for i := range s.Controls {
+		if s.Controls[i] != o.Controls[i] {
+			return false
+		}
+	}
+	for i := range s.Weights {
+		if s.Weights[i] != o.Weights[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Drill is a hole drilled at a single point
+type Drill struct {
+	At       Vector
+	Diameter float64
+}
+
+// Move returns the position of the hole, as both start and end point
+func (d Drill) Move() (Vector, Vector) {
+	return d.At, d.At
+}
+
+// Reverse does nothing, a hole has no direction
+func (d *Drill) Reverse() {
+}
+
+func (d Drill) Equal(m Move) bool {
+	if m, ok := m.(*Drill); ok {
+		return d.At == m.At && d.Diameter == m.Diameter
 	}
 	return false
 }
+
+func (d Drill) String() string {
+	return fmt.Sprintf("Drill<(%.2f,%.2f) %.2f>", d.At.X, d.At.Y, d.Diameter)
+}
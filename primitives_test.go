@@ -1,13 +1,14 @@
 package main
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestLineReverse(t *testing.T) {
-	v, w := Vector{0, 0}, Vector{1, 1}
+	v, w := Vector{0, 0, 0}, Vector{1, 1, 0}
 	a, b := &Line{v, w}, &Line{w, v}
 	b.Reverse()
 
@@ -15,20 +16,80 @@ func TestLineReverse(t *testing.T) {
 }
 
 func TestLineEqual(t *testing.T) {
-	l1 := &Line{Vector{0, 0}, Vector{1, 1}}
-	l2 := &Line{Vector{0, 0}, Vector{1, 1}}
+	l1 := &Line{Vector{0, 0, 0}, Vector{1, 1, 0}}
+	l2 := &Line{Vector{0, 0, 0}, Vector{1, 1, 0}}
 	assert.Equal(t, true, l1.Equal(l2), "should be equal")
 }
 
 func TestArcReverse(t *testing.T) {
-	a := &Arc{Vector{1, 0}, Vector{-1, 0}, Vector{0, 0}, false}
-	b := &Arc{Vector{-1, 0}, Vector{1, 0}, Vector{0, 0}, true}
+	a := &Arc{Vector{1, 0, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, false}
+	b := &Arc{Vector{-1, 0, 0}, Vector{1, 0, 0}, Vector{0, 0, 0}, true}
 	b.Reverse()
 	assert.Equal(t, a, b, "Arc.Reverse failed")
 }
 
 func TestArcEqual(t *testing.T) {
-	a1 := &Arc{Vector{-1, 0}, Vector{1, 0}, Vector{0, 0}, false}
-	a2 := &Arc{Vector{-1, 0}, Vector{1, 0}, Vector{0, 0}, false}
+	a1 := &Arc{Vector{-1, 0, 0}, Vector{1, 0, 0}, Vector{0, 0, 0}, false}
+	a2 := &Arc{Vector{-1, 0, 0}, Vector{1, 0, 0}, Vector{0, 0, 0}, false}
 	assert.Equal(t, true, a1.Equal(a2), "should be equal")
 }
+
+func TestLineMeasures(t *testing.T) {
+	l := Line{Vector{0, 0, 0}, Vector{3, 4, 0}}
+	assert.Equal(t, 5.0, l.Length())
+	assertNear(t, Vector{0.6, 0.8, 0}, l.At(1))
+	assertNear(t, Vector{3, 4, 0}, l.At(10))
+	assertNear(t, Vector{0.6, 0.8, 0}, l.Tangent(2))
+
+	a, b := l.Split(2.5)
+	assertNear(t, Vector{1.5, 2, 0}, a.To)
+	assert.Equal(t, a.To, b.From)
+
+	assertNear(t, Vector{0, 0, 0}, l.Closest(Vector{-1, -1, 0}))
+	assertNear(t, Vector{3, 4, 0}, l.Closest(Vector{7, 7, 0}))
+	assertNear(t, Vector{1.5, 2, 0}, l.Closest(Vector{1.5, 2, 0}.Sum(Vector{4, -3, 0})))
+	lb := l.Bounds()
+	assert.Equal(t, Vector{0, 0, 0}, lb.Min)
+	assert.Equal(t, Vector{3, 4, 0}, lb.Max)
+}
+
+func TestArcMeasures(t *testing.T) {
+	// quarter circle, clockwise from the top to the right
+	a := Arc{Vector{0, 2, 0}, Vector{2, 0, 0}, Vector{0, 0, 0}, true}
+	assert.InDelta(t, -math.Pi/2, a.Sweep(), 1e-9)
+	assert.InDelta(t, math.Pi, a.Length(), 1e-9)
+	assertNear(t, Vector{math.Sqrt2, math.Sqrt2, 0}, a.At(math.Pi/2))
+	assertNear(t, Vector{1, 0, 0}, a.Tangent(0))
+	assertNear(t, Vector{0, -1, 0}, a.Tangent(a.Length()))
+
+	first, second := a.Split(math.Pi / 2)
+	assert.InDelta(t, math.Pi/2, first.Length(), 1e-9)
+	assert.InDelta(t, math.Pi/2, second.Length(), 1e-9)
+	assert.True(t, first.CW && second.CW)
+
+	assertNear(t, Vector{math.Sqrt2, math.Sqrt2, 0}, a.Closest(Vector{5, 5, 0}))
+	assertNear(t, Vector{2, 0, 0}, a.Closest(Vector{1, -5, 0}))
+
+	// half circle going through the bottom: bounds include its lowest point
+	h := Arc{Vector{1, 0, 0}, Vector{-1, 0, 0}, Vector{0, 0, 0}, true}
+	b := h.Bounds()
+	assertNear(t, Vector{-1, -1, 0}, b.Min)
+	assertNear(t, Vector{1, 0, 0}, b.Max)
+}
+
+func TestSplineEqual(t *testing.T) {
+	s := &Spline{
+		Degree:   2,
+		Knots:    []float64{0, 0, 0, 1, 1, 1},
+		Controls: []Vector{{0, 0, 0}, {1, 1, 0}, {2, 0, 0}},
+		Weights:  []float64{1, 1, 1},
+	}
+	assert.True(t, s.Equal(clone(s)))
+	o := clone(s).(*Spline)
+	o.Controls[1].Y = 2
+	assert.False(t, s.Equal(o))
+	o = clone(s).(*Spline)
+	o.Weights[1] = 0.5
+	assert.False(t, s.Equal(o))
+	assert.False(t, s.Equal(&Line{}))
+}
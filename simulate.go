@@ -0,0 +1,320 @@
+package main
+
+// This file contains a simulation of material removal: the stock is a grid of
+// heights, and the tool is swept along the toolpath, lowering every cell under
+// it. The grid is split in bands of rows, processed in parallel.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Shapes of tools
+const (
+	FlatEnd  = "flat"
+	BallEnd  = "ball"
+	VBit     = "v"
+	DrillBit = "drill"
+	Engraver = "engraver"
+)
+
+// Tool describes a tool, and the shape of its cutting part
+type Tool struct {
+	Number      int     `json:"number"` // position in the tool changer, or in the library
+	Name        string  `json:"name"`
+	Shape       string  `json:"shape"`
+	Diameter    float64 `json:"diameter"`
+	Angle       float64 `json:"angle"` // included angle of the tip of V bits, engravers and drills, in degrees
+	Flutes      int     `json:"flutes"`
+	FluteLength float64 `json:"flute_length"` // 0 if unknown
+}
+
+// profile returns the height of the cutting edge above the tip of the tool, at
+// a distance d from its axis, or false if d is outside of the tool.
+func (t Tool) profile(d float64) (float64, bool) {
+	r := t.Diameter / 2
+	if d > r {
+		return 0, false
+	}
+	switch t.Shape {
+	case BallEnd:
+		return r - math.Sqrt(r*r-d*d), true
+	case VBit, Engraver, DrillBit:
+		return d / math.Tan(t.Angle*math.Pi/360), true
+	default:
+		return 0, true
+	}
+}
+
+// Heightmap is the top surface of the stock, sampled at the center of square
+// cells. The top of the stock is at Z=0.
+type Heightmap struct {
+	Origin     Vector  // corner of the first cell
+	Resolution float64 // size of the cells
+	Width      int
+	Height     int
+	Bottom     float64 // bottom of the stock, negative
+	Z          []float64
+}
+
+// NewHeightmap returns an uncut stock covering the given box
+func NewHeightmap(b Box, thickness, resolution float64) *Heightmap {
+	size := b.Max.Diff(b.Min)
+	h := &Heightmap{
+		Origin:     b.Min,
+		Resolution: resolution,
+		Width:      int(math.Max(1, math.Ceil(size.X/resolution))),
+		Height:     int(math.Max(1, math.Ceil(size.Y/resolution))),
+		Bottom:     -thickness,
+	}
+	h.Z = make([]float64, h.Width*h.Height)
+	return h
+}
+
+// cell returns the center of a cell
+func (h *Heightmap) cell(i, j int) Vector {
+	return Vector{
+		h.Origin.X + (float64(i)+0.5)*h.Resolution,
+		h.Origin.Y + (float64(j)+0.5)*h.Resolution,
+		0,
+	}
+}
+
+// stamp is a position of the tip of the tool
+type stamp struct {
+	At Vector
+	Z  float64
+}
+
+// stamps returns the positions of the tool along a toolpath, close enough to
+// each other to leave no gap in the heightmap.
+func (h *Heightmap) stamps(tp Toolpath) []stamp {
+	step := h.Resolution / 2
+	ss := []stamp{}
+	for _, p := range tp.Passes {
+		z := -p.Depth
+		for _, m := range p.Path {
+			from, to := m.Move()
+			switch m := m.(type) {
+			case *Arc:
+				sweep := math.Abs(m.Sweep())
+				n := int(math.Ceil(sweep*m.radius()/step)) + 1
+				for i := 0; i <= n; i++ {
+					t := float64(i) / float64(n)
+					ss = append(ss, stamp{m.at(sweep * t), z + from.Z + t*(to.Z-from.Z)})
+				}
+			default:
+				d := to.Diff(from)
+				n := int(math.Ceil(d.Norm()/step)) + 1
+				for i := 0; i <= n; i++ {
+					p := from.Sum(d.Multiply(float64(i) / float64(n)))
+					ss = append(ss, stamp{p, z + p.Z})
+				}
+			}
+		}
+	}
+	return ss
+}
+
+// Cut sweeps the tool along the toolpath
+func (h *Heightmap) Cut(tp Toolpath, tool Tool) {
+	ss := h.stamps(tp)
+	r := tool.Diameter / 2
+	reach := int(math.Ceil(r/h.Resolution)) + 1
+
+	workers := runtime.NumCPU()
+	band := (h.Height + workers - 1) / workers
+	wg := sync.WaitGroup{}
+	for first := 0; first < h.Height; first += band {
+		last := first + band
+		if last > h.Height {
+			last = h.Height
+		}
+		wg.Add(1)
+		go func(first, last int) {
+			defer wg.Done()
+			for _, s := range ss {
+				ci := int(math.Floor((s.At.X - h.Origin.X) / h.Resolution))
+				cj := int(math.Floor((s.At.Y - h.Origin.Y) / h.Resolution))
+				for j := cj - reach; j <= cj+reach; j++ {
+					if j < first || j >= last {
+						continue
+					}
+					for i := ci - reach; i <= ci+reach; i++ {
+						if i < 0 || i >= h.Width {
+							continue
+						}
+						dz, ok := tool.profile(h.cell(i, j).Diff(s.At).Norm())
+						if !ok {
+							continue
+						}
+						k := j*h.Width + i
+						h.Z[k] = math.Min(h.Z[k], math.Max(s.Z+dz, h.Bottom))
+					}
+				}
+			}
+		}(first, last)
+	}
+	wg.Wait()
+}
+
+// Simulate cuts a stock of the given size, from the origin, with the tool
+// following the toolpath. If a dimension of the stock is 0, the stock is fitted
+// around the toolpath.
+func Simulate(tp Toolpath, tool Tool, stock [3]float64, resolution float64) *Heightmap {
+	b := Box{Vector{0, 0, 0}, Vector{stock[0], stock[1], 0}}
+	thickness := stock[2]
+	if len(tp.Passes) > 0 {
+		tb := tp.Bounds()
+		r := tool.Diameter / 2
+		if stock[0] == 0 || stock[1] == 0 {
+			b = Box{Vector{tb.Min.X - r, tb.Min.Y - r, 0}, Vector{tb.Max.X + r, tb.Max.Y + r, 0}}
+		}
+		if thickness == 0 {
+			thickness = -tb.Min.Z
+		}
+	}
+	h := NewHeightmap(b, thickness, resolution)
+	h.Cut(tp, tool)
+	return h
+}
+
+// Report compares the simulated stock to the intended result
+type Report struct {
+	Area      float64 // area of the region
+	Cleared   float64 // area of the region cut down to the depth
+	Remaining float64 // volume of material left in the region above the depth
+	Outside   float64 // area cut outside the region
+}
+
+func (r Report) String() string {
+	cleared := 100.0
+	if r.Area > 0 {
+		cleared = 100 * r.Cleared / r.Area
+	}
+	return fmt.Sprintf("%.1f%% of %g cleared, %g left, %g cut outside",
+		cleared, r.Area, r.Remaining, r.Outside)
+}
+
+// Compare measures how well the region, made of closed paths, was cut down to
+// the given depth.
+func (h *Heightmap) Compare(region []Path, depth float64) Report {
+	region = orient(region)
+	cell := h.Resolution * h.Resolution
+	rep := Report{}
+	for j := 0; j < h.Height; j++ {
+		for i := 0; i < h.Width; i++ {
+			z := h.Z[j*h.Width+i]
+			if winding(region, h.cell(i, j)) == 0 {
+				if z < -EPSILON {
+					rep.Outside += cell
+				}
+				continue
+			}
+			rep.Area += cell
+			if left := z + depth; left > EPSILON {
+				rep.Remaining += left * cell
+			} else {
+				rep.Cleared += cell
+			}
+		}
+	}
+	return rep
+}
+
+// PNG writes the heightmap as a 16 bits greyscale image, white for the top of
+// the stock and black for its bottom. Stock with no thickness is all white.
+func (h *Heightmap) PNG(w io.Writer) error {
+	img := image.NewGray16(image.Rect(0, 0, h.Width, h.Height))
+	for j := 0; j < h.Height; j++ {
+		for i := 0; i < h.Width; i++ {
+			v := 1.0
+			if h.Bottom < 0 {
+				v -= h.Z[j*h.Width+i] / h.Bottom
+			}
+			// images go down, Y goes up
+			img.SetGray16(i, h.Height-1-j, color.Gray16{Y: uint16(math.Round(v * 0xffff))})
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// STL writes the stock as a closed binary STL mesh
+func (h *Heightmap) STL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	type point [3]float64
+	tris := [][3]point{}
+	tri := func(a, b, c point) {
+		tris = append(tris, [3]point{a, b, c})
+	}
+	top := func(i, j int) point {
+		c := h.cell(i, j)
+		return point{c.X, c.Y, h.Z[j*h.Width+i]}
+	}
+	bottom := func(i, j int) point {
+		c := h.cell(i, j)
+		return point{c.X, c.Y, h.Bottom}
+	}
+
+	for j := 0; j+1 < h.Height; j++ {
+		for i := 0; i+1 < h.Width; i++ {
+			tri(top(i, j), top(i+1, j), top(i+1, j+1))
+			tri(top(i, j), top(i+1, j+1), top(i, j+1))
+			tri(bottom(i, j), bottom(i+1, j+1), bottom(i+1, j))
+			tri(bottom(i, j), bottom(i, j+1), bottom(i+1, j+1))
+		}
+	}
+	// sides, going around counterclockwise
+	side := func(a, b [2]int) {
+		tri(bottom(a[0], a[1]), bottom(b[0], b[1]), top(b[0], b[1]))
+		tri(bottom(a[0], a[1]), top(b[0], b[1]), top(a[0], a[1]))
+	}
+	for i := 0; i+1 < h.Width; i++ {
+		side([2]int{i, 0}, [2]int{i + 1, 0})
+		side([2]int{i + 1, h.Height - 1}, [2]int{i, h.Height - 1})
+	}
+	for j := 0; j+1 < h.Height; j++ {
+		side([2]int{h.Width - 1, j}, [2]int{h.Width - 1, j + 1})
+		side([2]int{0, j + 1}, [2]int{0, j})
+	}
+
+	header := make([]byte, 80)
+	copy(header, "gocam heightmap")
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint32(len(tris))); err != nil {
+		return err
+	}
+	for _, t := range tris {
+		u := [3]float64{t[1][0] - t[0][0], t[1][1] - t[0][1], t[1][2] - t[0][2]}
+		v := [3]float64{t[2][0] - t[0][0], t[2][1] - t[0][1], t[2][2] - t[0][2]}
+		n := [3]float64{u[1]*v[2] - u[2]*v[1], u[2]*v[0] - u[0]*v[2], u[0]*v[1] - u[1]*v[0]}
+		l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+		data := make([]float32, 0, 12)
+		for _, c := range n {
+			if l > 0 {
+				c /= l
+			}
+			data = append(data, float32(c))
+		}
+		for _, p := range t {
+			data = append(data, float32(p[0]), float32(p[1]), float32(p[2]))
+		}
+		if err := binary.Write(bw, binary.LittleEndian, data); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.LittleEndian, uint16(0)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
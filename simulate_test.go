@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolProfile(t *testing.T) {
+	z, ok := Tool{Shape: BallEnd, Diameter: 2}.profile(1)
+	assert.True(t, ok)
+	assert.InDelta(t, 1, z, 1e-9)
+	z, ok = Tool{Shape: VBit, Diameter: 6, Angle: 90}.profile(2)
+	assert.True(t, ok)
+	assert.InDelta(t, 2, z, 1e-9)
+	_, ok = Tool{Shape: FlatEnd, Diameter: 2}.profile(1.5)
+	assert.False(t, ok)
+}
+
+func TestSimulatePocket(t *testing.T) {
+	// a 10x10 square, cleared by a zigzag of a 2mm flat end mill
+	zigzag := Path{}
+	for y := 1.0; y < 9.5; y += 1 {
+		zigzag = append(zigzag, &Line{Vector{1, y, 0}, Vector{9, y, 0}})
+		if y+1 < 9.5 {
+			zigzag = append(zigzag, &Line{Vector{9, y, 0}, Vector{9, y + 1, 0}})
+		}
+	}
+	tp := Toolpath{SafeZ: 5, Passes: []Pass{{Depth: 2, Path: zigzag}}}
+	tool := Tool{Shape: FlatEnd, Diameter: 2}
+	h := Simulate(tp, tool, [3]float64{10, 10, 5}, 0.1)
+
+	// the corners are rounded by the tool
+	rep := h.Compare([]Path{square(0.5, 0.5, 9)}, 2)
+	assert.InDelta(t, 81, rep.Area, 1e-6)
+	assert.InDelta(t, 81, rep.Cleared, 1e-6)
+	assert.Equal(t, 0.0, rep.Remaining)
+
+	// a deeper pocket is not cleared
+	rep = h.Compare([]Path{square(0.5, 0.5, 9)}, 3)
+	assert.Equal(t, 0.0, rep.Cleared)
+	assert.InDelta(t, 81, rep.Remaining, 1e-6)
+
+	// the stock is also cut outside of a smaller region
+	rep = h.Compare([]Path{square(0, 0, 5)}, 2)
+	assert.InDelta(t, 75-(4-3.14159), rep.Outside, 0.2)
+}
+
+func TestHeightmapExport(t *testing.T) {
+	tp := Toolpath{SafeZ: 5, Passes: []Pass{{Depth: 1, Path: Path{&Line{Vector{0, 5, 0}, Vector{10, 5, 0}}}}}}
+	h := Simulate(tp, Tool{Shape: BallEnd, Diameter: 2}, [3]float64{10, 10, 2}, 0.5)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, h.PNG(buf))
+	img, err := png.Decode(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, h.Width, img.Bounds().Dx())
+
+	buf.Reset()
+	assert.NoError(t, h.STL(buf))
+	n := binary.LittleEndian.Uint32(buf.Bytes()[80:84])
+	assert.Equal(t, 84+50*int(n), buf.Len())
+	// top and bottom, 2 triangles per cell, and 2 per cell along the sides
+	cells := (h.Width - 1) * (h.Height - 1)
+	assert.Equal(t, 4*cells+4*(h.Width-1)+4*(h.Height-1), int(n))
+}
+
+// failingWriter refuses everything written to it
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestHeightmapEmpty(t *testing.T) {
+	// nothing cut, and no stock thickness
+	h := Simulate(Toolpath{SafeZ: 5}, Tool{Diameter: 2}, [3]float64{4, 4, 0}, 1)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, h.PNG(buf))
+	img, err := png.Decode(buf)
+	assert.NoError(t, err)
+	r, _, _, _ := img.At(1, 1).RGBA()
+	assert.Equal(t, uint32(0xffff), r, "the stock is white")
+
+	assert.Error(t, h.STL(failingWriter{}))
+}
@@ -0,0 +1,198 @@
+package main
+
+// This file contains the rendering of models and toolpaths as SVG images, to
+// check a job before sending it to the machine. The output only depends on its
+// input, so it can be compared to a reference file.
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// colors used to tell operations apart
+var palette = []string{
+	"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd",
+	"#8c564b", "#e377c2", "#17becf", "#bcbd22", "#7f7f7f",
+}
+
+// SVG renders models and toolpaths
+type SVG struct {
+	Precision int
+	Tool      float64 // diameter of the tool, used as stroke width of the cuts if not 0
+	ColorBy   string  // "depth" or "operation"
+}
+
+// svgWriter keeps the first error, like DXFWriter
+type svgWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (sw *svgWriter) printf(format string, args ...interface{}) {
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, format, args...)
+	}
+}
+
+// Render writes the model, drawn as thin grey lines, with the toolpath on top
+// of it. Both can be empty.
+func (s SVG) Render(w io.Writer, m Model, tp Toolpath) error {
+	f := func(v float64) string {
+		return formatFloat(v, s.Precision)
+	}
+
+	// bounds of the drawing
+	b := m.Bounds()
+	for _, p := range tp.Passes {
+		b = b.union(p.Path.Bounds())
+	}
+	if b.Empty() {
+		b = Box{}
+	}
+	size := b.Max.Diff(b.Min)
+	hairline := math.Max(size.Norm()/500, math.Pow10(-s.Precision))
+	margin := s.Tool + 10*hairline
+
+	sw := &svgWriter{w: bufio.NewWriter(w)}
+	sw.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	sw.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%smm\" height=\"%smm\" viewBox=\"%s %s %s %s\">\n",
+		f(size.X+2*margin), f(size.Y+2*margin),
+		f(b.Min.X-margin), f(-b.Max.Y-margin), f(size.X+2*margin), f(size.Y+2*margin))
+	// flip the Y axis, so the drawing is in the coordinates of the machine
+	sw.printf("<g transform=\"scale(1,-1)\" fill=\"none\" stroke-linecap=\"round\" stroke-linejoin=\"round\">\n")
+
+	if len(m) > 0 {
+		sw.printf("<g id=\"model\" stroke=\"#999999\" stroke-width=\"%s\">\n", f(hairline))
+		for _, p := range m {
+			// holes are drawn with their diameter, points as dots
+			for _, mv := range p {
+				if d, ok := mv.(*Drill); ok {
+					r := math.Max(d.Diameter/2, 2*hairline)
+					sw.printf("<circle cx=\"%s\" cy=\"%s\" r=\"%s\"/>\n", f(d.At.X), f(d.At.Y), f(r))
+				}
+			}
+			if data := s.pathData(p); data != "" {
+				sw.printf("<path d=\"%s\"/>\n", data)
+			}
+		}
+		sw.printf("</g>\n")
+	}
+
+	if len(tp.Passes) > 0 {
+		width := s.Tool
+		if width == 0 {
+			width = 2 * hairline
+		}
+		arrow := math.Max(width, 8*hairline)
+		minDepth, maxDepth := tp.Passes[0].Depth, tp.Passes[0].Depth
+		for _, p := range tp.Passes {
+			minDepth = math.Min(minDepth, p.Depth)
+			maxDepth = math.Max(maxDepth, p.Depth)
+		}
+		ops := map[string]int{}
+
+		sw.printf("<g id=\"toolpath\">\n")
+		var pos Vector
+		for i, p := range tp.Passes {
+			start, end := p.Path.Move()
+			color := ""
+			if s.ColorBy == "operation" {
+				if _, ok := ops[p.Operation]; !ok {
+					ops[p.Operation] = len(ops)
+				}
+				color = palette[ops[p.Operation]%len(palette)]
+			} else {
+				color = depthColor(p.Depth, minDepth, maxDepth)
+			}
+
+			newStart := i == 0 || pos != start
+			if i > 0 && newStart {
+				sw.printf("<path d=\"M%s %s L%s %s\" stroke=\"#000000\" stroke-width=\"%s\" stroke-dasharray=\"%s %s\"/>\n",
+					f(pos.X), f(pos.Y), f(start.X), f(start.Y), f(hairline), f(4*hairline), f(4*hairline))
+			}
+
+			if d, ok := p.Path[0].(*Drill); ok && len(p.Path) == 1 {
+				r := d.Diameter / 2
+				if r == 0 {
+					r = width / 2
+				}
+				sw.printf("<circle cx=\"%s\" cy=\"%s\" r=\"%s\" fill=\"%s\" fill-opacity=\"0.5\"/>\n",
+					f(d.At.X), f(d.At.Y), f(r), color)
+			} else {
+				sw.printf("<path d=\"%s\" stroke=\"%s\" stroke-width=\"%s\" stroke-opacity=\"0.6\"/>\n",
+					s.pathData(p.Path), color, f(width))
+				if newStart {
+					// start point, and an arrow giving the direction
+					t := tangent(p.Path[0], start)
+					n := Vector{-t.Y, t.X, 0}
+					tip := start.Sum(t.Multiply(arrow * 2))
+					left := start.Sum(n.Multiply(arrow / 2))
+					right := start.Diff(n.Multiply(arrow / 2))
+					sw.printf("<circle cx=\"%s\" cy=\"%s\" r=\"%s\" fill=\"#000000\"/>\n",
+						f(start.X), f(start.Y), f(arrow/2))
+					sw.printf("<path d=\"M%s %s L%s %s L%s %s Z\" fill=\"#000000\"/>\n",
+						f(left.X), f(left.Y), f(tip.X), f(tip.Y), f(right.X), f(right.Y))
+				}
+			}
+			pos = end
+		}
+		sw.printf("</g>\n")
+	}
+
+	sw.printf("</g>\n</svg>\n")
+	if sw.err != nil {
+		return sw.err
+	}
+	return sw.w.Flush()
+}
+
+// pathData returns the SVG path data of a path. Drills are left out.
+func (s SVG) pathData(p Path) string {
+	f := func(v float64) string {
+		return formatFloat(v, s.Precision)
+	}
+	parts := []string{}
+	var pos Vector
+	for _, m := range p {
+		if _, ok := m.(*Drill); ok {
+			continue
+		}
+		from, to := m.Move()
+		if len(parts) == 0 || pos != from {
+			parts = append(parts, fmt.Sprintf("M%s %s", f(from.X), f(from.Y)))
+		}
+		switch m := m.(type) {
+		case *Arc:
+			r := m.radius()
+			sweep := m.Sweep()
+			flag := 0
+			if sweep > 0 {
+				flag = 1
+			}
+			if math.Abs(sweep) > math.Pi*(1-1e-9) {
+				// large arcs are drawn in two parts, so half and full
+				// circles are not ambiguous
+				mid := m.at(math.Abs(sweep) / 2)
+				parts = append(parts, fmt.Sprintf("A%s %s 0 0 %d %s %s", f(r), f(r), flag, f(mid.X), f(mid.Y)))
+			}
+			parts = append(parts, fmt.Sprintf("A%s %s 0 0 %d %s %s", f(r), f(r), flag, f(to.X), f(to.Y)))
+		default:
+			parts = append(parts, fmt.Sprintf("L%s %s", f(to.X), f(to.Y)))
+		}
+		pos = to
+	}
+	return strings.Join(parts, " ")
+}
+
+// depthColor returns a color going from blue for the shallowest passes to red
+// for the deepest ones.
+func depthColor(depth, min, max float64) string {
+	t := 1.0
+	if max > min {
+		t = (depth - min) / (max - min)
+	}
+	return fmt.Sprintf("#%02x00%02x", int(math.Round(255*t)), int(math.Round(255*(1-t))))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// golden compares out with the content of testdata/name, or replaces it when
+// the tests are run with -update
+func golden(t *testing.T, name string, out []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(path, out, 0644))
+	}
+	expected, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(out), "output differs from "+path)
+}
+
+func TestSVGPreview(t *testing.T) {
+	m := Model{
+		square(0, 0, 10),
+		circle(Vector{5, 5, 0}, 2),
+		Path{&Drill{Vector{20, 5, 0}, 3}},
+	}
+	profile := Operation{Name: "profile", Kind: Profile, Tool: 2, Depth: 2, PassDepth: 1}
+	passes, err := profile.Passes(m[:1])
+	assert.NoError(t, err)
+	drill := Operation{Name: "drill", Kind: Drilling, Depth: 5}
+	holes, err := drill.Passes(m[2:])
+	assert.NoError(t, err)
+	tp := Toolpath{SafeZ: 5, Passes: append(passes, holes...)}
+
+	var buf bytes.Buffer
+	svg := SVG{Precision: 3, Tool: 2, ColorBy: "depth"}
+	assert.NoError(t, svg.Render(&buf, m, tp))
+	golden(t, "preview.svg", buf.Bytes())
+}
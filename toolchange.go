@@ -0,0 +1,108 @@
+package main
+
+// This file contains the tool changes of toolpaths using several tools: the
+// passes are grouped by tool, and each change is made either by the machine
+// (M6), or by hand during a pause, for controllers like grbl that do not
+// handle M6.
+
+import (
+	"fmt"
+
+	"github.com/joushou/gocnc/gcode"
+)
+
+// Kinds of tool changes
+const (
+	ToolChanger  = "m6"    // M6 Tn, done by the controller
+	ManualChange = "pause" // stop and pause with M0, the first tool is loaded before starting
+)
+
+// ToolChange describes how tools are changed between passes
+type ToolChange struct {
+	Kind         string  // ToolChanger or ManualChange, no tool change if empty
+	LengthOffset bool    // apply the tool length offset with G43 after M6
+	At           *Vector // position of manual changes, the tool stays above the part if nil
+}
+
+// toolChange returns the blocks changing to the tool, the tool being
+// retracted at safe height. The spindle is stopped if it is running.
+func (tp Toolpath) toolChange(tool int, first, spindle bool) []gcode.Block {
+	blocks := []gcode.Block{}
+	block := func(nodes ...gcode.Node) {
+		blocks = append(blocks, gcode.Block{Nodes: nodes})
+	}
+	comment := func(format string, args ...interface{}) {
+		block(&gcode.Comment{Content: fmt.Sprintf(format, args...)})
+	}
+	c := tp.Change
+	if spindle {
+		block(word('M', 5))
+	}
+	switch c.Kind {
+	case ToolChanger:
+		block(word('T', float64(tool)), word('M', 6))
+		if c.LengthOffset {
+			block(word('G', 43), word('H', float64(tool)))
+		}
+	case ManualChange:
+		if first {
+			comment("Tool %d", tool)
+			break
+		}
+		high := c.At != nil && c.At.Z > tp.SafeZ
+		if high {
+			block(word('G', 0), word('Z', c.At.Z))
+		}
+		if c.At != nil {
+			blocks = append(blocks, move(*c.At))
+		}
+		comment("Change to tool %d", tool)
+		block(word('M', 0))
+		if high {
+			block(word('G', 0), word('Z', tp.SafeZ))
+		}
+	}
+	return blocks
+}
+
+// tools returns the tools of the passes, in the order of their first use
+func (tp Toolpath) tools() []int {
+	seen := map[int]bool{}
+	tools := []int{}
+	for _, p := range tp.Passes {
+		if !seen[p.Tool] {
+			seen[p.Tool] = true
+			tools = append(tools, p.Tool)
+		}
+	}
+	return tools
+}
+
+// Grouped returns a copy of the toolpath where the passes of each tool are
+// machined together, in the order the tools are first used, so each tool is
+// loaded once. The passes of a tool keep their order.
+func (tp Toolpath) Grouped() Toolpath {
+	res := tp
+	res.Passes = []Pass{}
+	for _, t := range tp.Split() {
+		res.Passes = append(res.Passes, t.Passes...)
+	}
+	return res
+}
+
+// Split returns a toolpath for each tool, in the order the tools are first
+// used, so each tool can be run as its own program.
+func (tp Toolpath) Split() []Toolpath {
+	res := []Toolpath{}
+	for _, tool := range tp.tools() {
+		t := tp
+		t.Passes = []Pass{}
+		for _, p := range tp.Passes {
+			if p.Tool == tool {
+				t.Passes = append(t.Passes, p)
+			}
+		}
+		res = append(res, t)
+	}
+	return res
+}
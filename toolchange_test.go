@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toolsPath returns a toolpath cutting squares with the given tools
+func toolsPath(tools ...int) Toolpath {
+	tp := Toolpath{SafeZ: 5}
+	for i, tool := range tools {
+		tp.Passes = append(tp.Passes, Pass{
+			Depth: 1,
+			Speed: 10000,
+			Tool:  tool,
+			Path:  square(float64(20*i), 0, 10),
+		})
+	}
+	return tp
+}
+
+func TestGrouped(t *testing.T) {
+	tp := toolsPath(2, 1, 2, 3, 1).Grouped()
+	tools := []int{}
+	for _, p := range tp.Passes {
+		tools = append(tools, p.Tool)
+	}
+	assert.Equal(t, []int{2, 2, 1, 1, 3}, tools)
+	b := tp.Passes[1].Path.Bounds()
+	assert.Equal(t, 40.0, b.Min.X, "the passes of a tool keep their order")
+
+	split := toolsPath(2, 1, 2).Split()
+	assert.Len(t, split, 2)
+	assert.Len(t, split[0].Passes, 2)
+	assert.Equal(t, 5.0, split[1].SafeZ)
+}
+
+func TestToolChanger(t *testing.T) {
+	tp := toolsPath(1, 2)
+	tp.Change = ToolChange{Kind: ToolChanger, LengthOffset: true}
+	doc := tp.Gcode()
+	out := doc.Export(3)
+	for _, s := range []string{"T1 M6\nG43 H1\n", "M5\nT2 M6\nG43 H2\n"} {
+		assert.True(t, strings.Contains(out, s), "missing %q", s)
+	}
+	assert.Equal(t, 2, strings.Count(out, "M3 S10000"), "the spindle starts again after the change")
+
+	in, err := ReadGcode(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, in.Toolpath.Passes, 2)
+	assert.Equal(t, 2, in.Toolpath.Passes[1].Tool)
+
+	// no changes
+	tp.Change = ToolChange{}
+	doc = tp.Gcode()
+	assert.False(t, strings.Contains(doc.Export(3), "M6"))
+}
+
+func TestManualChange(t *testing.T) {
+	tp := toolsPath(1, 2, 2)
+	tp.Change = ToolChange{Kind: ManualChange, At: &Vector{0, -50, 40}}
+	doc := tp.Gcode()
+	out := doc.Export(3)
+	assert.Equal(t, 1, strings.Count(out, "M0\n"), "the first tool is loaded before starting")
+	assert.True(t, strings.Contains(out, "M5\nG0 Z40\nG0 X0 Y-50\n(Change to tool 2)\nM0\nG0 Z5\n"))
+	assert.False(t, strings.Contains(out, "M6"))
+}
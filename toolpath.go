@@ -0,0 +1,132 @@
+package main
+
+// This file contains the toolpath, ie the sequence of passes sent to the
+// machine, and its conversion to Gcode.
+
+import (
+	"fmt"
+
+	"github.com/joushou/gocnc/gcode"
+)
+
+// Pass is a path machined at a given depth. The Z coordinates of the path are
+// relative to that depth, so flat paths have Z=0.
+type Pass struct {
+	Operation  string  // name of the operation the pass belongs to
+	Depth      float64 // positive below the surface
+	Feed       float64
+	PlungeFeed float64
+	Speed      float64 // spindle speed, 0 to leave it unchanged
+	Tool       int     // number of the tool, 0 if unknown
+	Dwell      float64 // pause before the plunge, in seconds
+	Path       Path
+}
+
+// Toolpath is the sequence of passes, in the order they are machined. Between
+// passes, the tool is retracted to SafeZ, unless the next pass starts where
+// the previous one ended.
+type Toolpath struct {
+	SafeZ  float64
+	Passes []Pass
+	Arcs   ArcFormat
+	Change ToolChange // tool changes between passes of different tools
+}
+
+func (tp Toolpath) Gcode() gcode.Document {
+	doc := &gcode.Document{}
+	block := func(nodes ...gcode.Node) {
+		doc.AppendBlock(gcode.Block{Nodes: nodes})
+	}
+
+	// millimetres, absolute coordinates
+	block(word('G', 21), word('G', 90))
+	block(word('G', 0), word('Z', tp.SafeZ))
+
+	var pos Vector
+	down := false
+	speed := 0.0
+	tool := 0
+	for i, p := range tp.Passes {
+		start, end := p.Path.Move()
+		start, end = p.actual(start), p.actual(end)
+		if p.Tool != 0 && p.Tool != tool && tp.Change.Kind != "" {
+			if down {
+				block(word('G', 0), word('Z', tp.SafeZ))
+			}
+			doc.Blocks = append(doc.Blocks, tp.toolChange(p.Tool, tool == 0, speed > 0)...)
+			tool, speed, down = p.Tool, 0, false
+		}
+		block(&gcode.Comment{
+			Content: fmt.Sprintf("Pass %d: %s at depth %g", i, p.Operation, p.Depth),
+		})
+		if !down || !pos.near(start) {
+			if down {
+				block(word('G', 0), word('Z', tp.SafeZ))
+			}
+			doc.AppendBlock(move(start))
+		}
+		if p.Speed > 0 && p.Speed != speed {
+			block(word('M', 3), word('S', p.Speed))
+			speed = p.Speed
+		}
+
+		if p.Dwell > 0 {
+			block(word('G', 4), word('P', p.Dwell))
+		}
+
+		// plunge
+		plunge := gcode.Block{}
+		z := start.Z
+		plunge.AppendNodes(word('G', 1), word('Z', z))
+		if f := p.plungeFeed(); f > 0 {
+			plunge.AppendNode(word('F', f))
+		}
+		doc.AppendBlock(plunge)
+
+		first := true
+		for _, m := range p.Path {
+			var bs []gcode.Block
+			switch m := m.(type) {
+			case *Line:
+				l := Line{p.actual(m.From), p.actual(m.To)}
+				bs = []gcode.Block{l.Gcode()}
+			case *Arc:
+				a := Arc{p.actual(m.From), p.actual(m.To), p.actual(m.Center), m.CW}
+				bs = tp.Arcs.Gcode(&a)
+			case Gcoder:
+				bs = []gcode.Block{m.Gcode()}
+			}
+			for _, b := range bs {
+				// the height is only given when it changes
+				b, z = height(b, z)
+				if first && p.Feed > 0 {
+					b.AppendNode(word('F', p.Feed))
+				}
+				first = false
+				doc.AppendBlock(b)
+			}
+		}
+		pos, down = end, true
+	}
+
+	block(word('G', 0), word('Z', tp.SafeZ))
+	if speed > 0 {
+		block(word('M', 5))
+	}
+	block(word('M', 2))
+	return *doc
+}
+
+// actual returns the position of the tool at a point of the pass, with its
+// actual height
+func (p Pass) actual(v Vector) Vector {
+	v.Z -= p.Depth
+	return v
+}
+
+func (p Pass) plungeFeed() float64 {
+	if p.PlungeFeed > 0 {
+		return p.PlungeFeed
+	}
+	return p.Feed
+}
@@ -0,0 +1,178 @@
+package main
+
+// This file contains the tool library: the tools of the machine, and the
+// cutting conditions of the materials, from which the spindle speed and the
+// feed rate of operations are computed.
+//
+//	{
+//		"min_speed": 8000,
+//		"max_speed": 24000,
+//		"tools": [
+//			{"number": 1, "name": "3 mm end mill", "shape": "flat", "diameter": 3, "flutes": 2, "flute_length": 12},
+//			{"number": 2, "name": "60° v bit", "shape": "v", "diameter": 6, "angle": 60, "flutes": 2}
+//		],
+//		"materials": [
+//			{"name": "plywood", "chip_load": 0.05, "surface_speed": 200}
+//		]
+//	}
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// DrillAngle is the point angle of twist drills, in degrees, used when it is
+// not given
+const DrillAngle = 118.0
+
+// setDefaults gives drills the DrillAngle point when their angle is not given
+func (t *Tool) setDefaults() {
+	if t.Shape == DrillBit && t.Angle == 0 {
+		t.Angle = DrillAngle
+	}
+}
+
+// Material describes the cutting conditions of a material
+type Material struct {
+	Name         string  `json:"name"`
+	ChipLoad     float64 `json:"chip_load"`     // thickness of the chips, in mm per tooth
+	SurfaceSpeed float64 `json:"surface_speed"` // speed of the cutting edge, in m/min
+}
+
+// Library holds the tools of the machine, and the materials it cuts
+type Library struct {
+	MinSpeed  float64    `json:"min_speed"` // range of the spindle, in RPM, no limit if 0
+	MaxSpeed  float64    `json:"max_speed"`
+	Tools     []Tool     `json:"tools"`
+	Materials []Material `json:"materials"`
+}
+
+// ReadLibrary reads a library file
+func ReadLibrary(name string) (*Library, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	l := &Library{}
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(l); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	numbers := map[int]bool{}
+	for i := range l.Tools {
+		t := &l.Tools[i]
+		t.setDefaults()
+		if t.Number <= 0 {
+			return nil, fmt.Errorf("%s: tool %d: numbers start at 1", name, t.Number)
+		}
+		switch t.Shape {
+		case FlatEnd, BallEnd, VBit, Engraver, DrillBit:
+		default:
+			return nil, fmt.Errorf("%s: tool %d: unknown shape %s", name, t.Number, t.Shape)
+		}
+		if t.Diameter <= 0 {
+			return nil, fmt.Errorf("%s: tool %d: no diameter", name, t.Number)
+		}
+		pointed := t.Shape == VBit || t.Shape == Engraver || t.Shape == DrillBit
+		if pointed && (t.Angle <= 0 || t.Angle >= 180) {
+			return nil, fmt.Errorf("%s: tool %d: angle %g out of 0 to 180°", name, t.Number, t.Angle)
+		}
+		if numbers[t.Number] {
+			return nil, fmt.Errorf("%s: duplicate tool %d", name, t.Number)
+		}
+		numbers[t.Number] = true
+	}
+	return l, nil
+}
+
+// Tool returns the tool with the given number
+func (l *Library) Tool(number int) (Tool, error) {
+	for _, t := range l.Tools {
+		if t.Number == number {
+			return t, nil
+		}
+	}
+	return Tool{}, fmt.Errorf("no tool %d in the library", number)
+}
+
+// Material returns the material with the given name
+func (l *Library) Material(name string) (Material, error) {
+	for _, m := range l.Materials {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return Material{}, fmt.Errorf("no material %s in the library", name)
+}
+
+// Cutting returns the spindle speed, in RPM, and the feed rate, in mm/min, of
+// a tool in a material. The speed is kept in the range of the spindle, and
+// the feed rate follows it so the chip load stays the same.
+func (l *Library) Cutting(t Tool, m Material) (speed, feed float64) {
+	speed = m.SurfaceSpeed * 1000 / (math.Pi * t.Diameter)
+	if l.MaxSpeed > 0 {
+		speed = math.Min(speed, l.MaxSpeed)
+	}
+	speed = math.Round(math.Max(speed, l.MinSpeed))
+	return speed, t.feed(m, speed)
+}
+
+// feed returns the feed rate giving the chip load of the material at the
+// given speed. Tools with no flutes given are taken to have one.
+func (t Tool) feed(m Material, speed float64) float64 {
+	flutes := math.Max(float64(t.Flutes), 1)
+	return math.Round(speed * flutes * m.ChipLoad)
+}
+
+// Setup makes the operation use the tool of the library with the given
+// number. The speed and feed rate that the operation leaves unset are computed
+// for the material, if it is not empty. It returns the tool.
+func (l *Library) Setup(op *Operation, number int, material string) (Tool, error) {
+	t, err := l.Tool(number)
+	if err != nil {
+		return t, err
+	}
+	op.Tool = t.Diameter
+	op.ToolNumber = t.Number
+	if t.Angle > 0 {
+		op.Angle = t.Angle
+	}
+	if material == "" {
+		return t, nil
+	}
+	m, err := l.Material(material)
+	if err != nil {
+		return t, err
+	}
+	speed, feed := l.Cutting(t, m)
+	if op.Speed == 0 {
+		op.Speed = speed
+	} else {
+		// keep the chip load at the speed asked for
+		feed = t.feed(m, op.Speed)
+	}
+	if op.Feed == 0 {
+		op.Feed = feed
+	}
+	return t, nil
+}
+
+// Check returns the problems of the operation done with the tool: cutting
+// deeper than the flutes, or stepping over more than the tool.
+func (op Operation) Check(t Tool) []error {
+	errs := []error{}
+	if t.FluteLength > 0 && op.Depth > t.FluteLength+EPSILON {
+		errs = append(errs, fmt.Errorf("%s: depth %g beyond the flute length %g of tool %d",
+			op.Name, op.Depth, t.FluteLength, t.Number))
+	}
+	if op.StepOver > t.Diameter+EPSILON {
+		errs = append(errs, fmt.Errorf("%s: step over %g larger than the diameter %g of tool %d",
+			op.Name, op.StepOver, t.Diameter, t.Number))
+	}
+	return errs
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testLibrary = `{
+	"max_speed": 18000,
+	"tools": [
+		{"number": 1, "name": "3 mm end mill", "shape": "flat", "diameter": 3, "flutes": 2, "flute_length": 8},
+		{"number": 2, "name": "6 mm end mill", "shape": "flat", "diameter": 6, "flutes": 2},
+		{"number": 3, "name": "v bit", "shape": "v", "diameter": 6, "angle": 60, "flutes": 1}
+	],
+	"materials": [
+		{"name": "plywood", "chip_load": 0.05, "surface_speed": 200}
+	]
+}`
+
+func writeLibrary(t *testing.T, dir, library string) string {
+	name := filepath.Join(dir, "tools.json")
+	assert.NoError(t, ioutil.WriteFile(name, []byte(library), 0644))
+	return name
+}
+
+func TestLibrary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocam")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	lib, err := ReadLibrary(writeLibrary(t, dir, testLibrary))
+	assert.NoError(t, err)
+	assert.Len(t, lib.Tools, 3)
+
+	plywood, err := lib.Material("plywood")
+	assert.NoError(t, err)
+	_, err = lib.Material("steel")
+	assert.Error(t, err)
+
+	// 200 m/min on a 6 mm tool
+	t6, err := lib.Tool(2)
+	assert.NoError(t, err)
+	speed, feed := lib.Cutting(t6, plywood)
+	assert.Equal(t, 10610.0, speed)
+	assert.Equal(t, 1061.0, feed)
+
+	// the spindle is too slow for the small tool, the feed rate follows
+	t3, _ := lib.Tool(1)
+	speed, feed = lib.Cutting(t3, plywood)
+	assert.Equal(t, 18000.0, speed)
+	assert.Equal(t, 1800.0, feed)
+
+	op := Operation{Name: "cut", Tool: 1, Depth: 10, StepOver: 4, Feed: 500}
+	tool, err := lib.Setup(&op, 1, "plywood")
+	assert.NoError(t, err)
+	assert.Equal(t, t3, tool)
+	assert.Equal(t, 3.0, op.Tool)
+	assert.Equal(t, 1, op.ToolNumber)
+	assert.Equal(t, 18000.0, op.Speed)
+	assert.Equal(t, 500.0, op.Feed, "the feed rate given is kept")
+	assert.Len(t, op.Check(tool), 2, "too deep for the flutes, and stepping over more than the tool")
+
+	op = Operation{Speed: 10000, Angle: 90}
+	tool, err = lib.Setup(&op, 3, "plywood")
+	assert.NoError(t, err)
+	assert.Equal(t, 60.0, op.Angle)
+	assert.Equal(t, 500.0, op.Feed, "the chip load is kept at the speed given")
+	assert.Empty(t, op.Check(tool))
+
+	_, err = lib.Setup(&op, 4, "plywood")
+	assert.Error(t, err)
+}
+
+func TestLibraryErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocam")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, library := range []string{
+		`{"tools": [{"number": 1, "shape": "square", "diameter": 3}]}`,
+		`{"tools": [{"number": 1, "shape": "flat"}]}`,
+		`{"tools": [{"number": 1, "shape": "flat", "diameter": 3}, {"number": 1, "shape": "ball", "diameter": 3}]}`,
+		`{"tools": [], "spindle": 24000}`,
+		`{"tools": [{"number": 1, "shape": "v", "diameter": 6}]}`,
+		`{"tools": [{"number": 1, "shape": "engraver", "diameter": 3, "angle": 180}]}`,
+		`{"tools": [{"shape": "flat", "diameter": 3}]}`,
+		`{"tools": [{"number": -2, "shape": "flat", "diameter": 3}]}`,
+	} {
+		_, err := ReadLibrary(writeLibrary(t, dir, library))
+		assert.Error(t, err, library)
+	}
+
+	lib, err := ReadLibrary(writeLibrary(t, dir, `{"tools": [{"number": 1, "shape": "drill", "diameter": 3}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, DrillAngle, lib.Tools[0].Angle, "drills have a 118° point by default")
+}
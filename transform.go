@@ -0,0 +1,158 @@
+package main
+
+// This file contains the geometric transformations of models: translations,
+// rotations, uniform scaling and mirrors. Moves are transformed in place, like
+// they are reversed in place.
+
+import (
+	"fmt"
+	"math"
+)
+
+// Transform is an affine transformation keeping the shapes: x' = A*x + B*y + E
+// and y' = C*x + D*y + F, where the linear part is a rotation or a mirror,
+// times a uniform scale.
+type Transform struct {
+	A, B, C, D float64
+	E, F       float64
+}
+
+// Identity returns the transformation leaving everything in place
+func Identity() Transform {
+	return Transform{A: 1, D: 1}
+}
+
+// Translation moves by v
+func Translation(v Vector) Transform {
+	return Transform{A: 1, D: 1, E: v.X, F: v.Y}
+}
+
+// Rotation turns counterclockwise around the origin, angle in radians
+func Rotation(angle float64) Transform {
+	sin, cos := math.Sincos(angle)
+	return Transform{A: cos, B: -sin, C: sin, D: cos}
+}
+
+// Scaling scales uniformly from the origin
+func Scaling(k float64) Transform {
+	return Transform{A: k, D: k}
+}
+
+// MirrorX flips the X coordinates, mirroring around the Y axis
+func MirrorX() Transform {
+	return Transform{A: -1, D: 1}
+}
+
+// MirrorY flips the Y coordinates, mirroring around the X axis
+func MirrorY() Transform {
+	return Transform{A: 1, D: -1}
+}
+
+// Then returns the transformation applying t, then o
+func (t Transform) Then(o Transform) Transform {
+	return Transform{
+		A: o.A*t.A + o.B*t.C,
+		B: o.A*t.B + o.B*t.D,
+		C: o.C*t.A + o.D*t.C,
+		D: o.C*t.B + o.D*t.D,
+		E: o.A*t.E + o.B*t.F + o.E,
+		F: o.C*t.E + o.D*t.F + o.F,
+	}
+}
+
+// Apply returns the transformed vector, the height is left unchanged
+func (t Transform) Apply(v Vector) Vector {
+	return Vector{t.A*v.X + t.B*v.Y + t.E, t.C*v.X + t.D*v.Y + t.F, v.Z}
+}
+
+// mirrors tells if the transformation changes the orientation
+func (t Transform) mirrors() bool {
+	return t.A*t.D-t.B*t.C < 0
+}
+
+// Transform returns the transformed vector
+func (v Vector) Transform(t Transform) Vector {
+	return t.Apply(v)
+}
+
+func (l *Line) Transform(t Transform) {
+	l.From, l.To = t.Apply(l.From), t.Apply(l.To)
+}
+
+// Transform transforms the arc, a mirrored arc turns the other way
+func (a *Arc) Transform(t Transform) {
+	a.From, a.To, a.Center = t.Apply(a.From), t.Apply(a.To), t.Apply(a.Center)
+	if t.mirrors() {
+		a.CW = !a.CW
+	}
+}
+
+// Transform transforms the control points, NURBS being invariant under affine
+// transformations
+func (s *Spline) Transform(t Transform) {
+	for i, c := range s.Controls {
+		s.Controls[i] = t.Apply(c)
+	}
+}
+
+// Transform moves the hole. Its diameter is kept, as it is the size of the
+// drill.
+func (d *Drill) Transform(t Transform) {
+	d.At = t.Apply(d.At)
+}
+
+// Transform transforms the moves of the path. Moves that are not Transformers
+// are left in place.
+func (p Path) Transform(t Transform) {
+	for _, m := range p {
+		if tm, ok := m.(Transformer); ok {
+			tm.Transform(t)
+		} else {
+			Log.Printf("Cannot transform move of type %T\n", m)
+		}
+	}
+}
+
+func (m Model) Transform(t Transform) {
+	for _, p := range m {
+		p.Transform(t)
+	}
+}
+
+// Bounds returns the bounding box of the model, empty if the model is empty
+func (m Model) Bounds() Box {
+	b := emptyBox()
+	for _, p := range m {
+		b = b.union(p.Bounds())
+	}
+	return b
+}
+
+// Placements of a model relative to the origin
+const (
+	CornerAtOrigin = "corner" // lower left corner of the bounding box
+	CenterAtOrigin = "center" // center of the bounding box
+)
+
+// Place translates the model so its bounding box is placed on the origin, and
+// returns the translation. An empty placement leaves the model where it is.
+func (m Model) Place(placement string) (Transform, error) {
+	t := Identity()
+	switch placement {
+	case CornerAtOrigin, CenterAtOrigin, "":
+	default:
+		return t, fmt.Errorf("unknown placement %s", placement)
+	}
+	b := m.Bounds()
+	if b.Empty() {
+		return t, nil
+	}
+	switch placement {
+	case CornerAtOrigin:
+		t = Translation(b.Min.Multiply(-1))
+	case CenterAtOrigin:
+		t = Translation(b.Min.Sum(b.Max).Divide(-2))
+	}
+	m.Transform(t)
+	return t, nil
+}
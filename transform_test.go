@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertNear(t *testing.T, expected, actual Vector) {
+	assert.InDelta(t, expected.X, actual.X, 1e-9)
+	assert.InDelta(t, expected.Y, actual.Y, 1e-9)
+}
+
+func TestTransformVector(t *testing.T) {
+	v := Vector{1, 2, 0}
+	assertNear(t, Vector{4, 6, 0}, v.Transform(Translation(Vector{3, 4, 0})))
+	assertNear(t, Vector{-2, 1, 0}, v.Transform(Rotation(math.Pi/2)))
+	assertNear(t, Vector{2, 4, 0}, v.Transform(Scaling(2)))
+	assertNear(t, Vector{-1, 2, 0}, v.Transform(MirrorX()))
+	assertNear(t, Vector{1, -2, 0}, v.Transform(MirrorY()))
+
+	// rotate, then translate
+	tr := Rotation(math.Pi / 2).Then(Translation(Vector{10, 0, 0}))
+	assertNear(t, Vector{8, 1, 0}, v.Transform(tr))
+}
+
+func TestTransformArc(t *testing.T) {
+	a := &Arc{Vector{1, 0, 0}, Vector{0, 1, 0}, Vector{0, 0, 0}, false}
+	a.Transform(Scaling(2))
+	assert.Equal(t, &Arc{Vector{2, 0, 0}, Vector{0, 2, 0}, Vector{0, 0, 0}, false}, a)
+	sweep := a.Sweep()
+
+	a.Transform(MirrorX())
+	assert.True(t, a.CW, "a mirrored arc turns the other way")
+	assert.InDelta(t, -sweep, a.Sweep(), 1e-9)
+	assert.InDelta(t, 2, a.radius(), 1e-9)
+}
+
+func TestTransformDrill(t *testing.T) {
+	d := &Drill{Vector{1, 1, 0}, 1}
+	d.Transform(Scaling(2).Then(MirrorY()))
+	assert.Equal(t, &Drill{Vector{2, -2, 0}, 1}, d, "the size of the hole is the size of the drill")
+}
+
+func TestModelPlace(t *testing.T) {
+	m := Model{circle(Vector{5, 5, 0}, 2), Path{&Line{Vector{1, 1, 0}, Vector{2, 2, 0}}}}
+	tr, err := m.Place(CornerAtOrigin)
+	assert.NoError(t, err)
+	b := m.Bounds()
+	assert.False(t, b.Empty())
+	assertNear(t, Vector{0, 0, 0}, b.Min)
+	assertNear(t, Vector{6, 6, 0}, b.Max)
+	assertNear(t, Vector{-1, -1, 0}, Vector{}.Transform(tr))
+
+	m.Place(CenterAtOrigin)
+	b = m.Bounds()
+	assertNear(t, Vector{-3, -3, 0}, b.Min)
+	assertNear(t, Vector{3, 3, 0}, b.Max)
+
+	_, err = m.Place("middle")
+	assert.Error(t, err)
+	tr, err = m.Place("")
+	assert.NoError(t, err)
+	assert.Equal(t, Identity(), tr)
+
+	// mirroring keeps the area, but changes the orientation
+	c := circle(Vector{0, 0, 0}, 1)
+	before := c.Area()
+	c.Transform(MirrorY())
+	assert.InDelta(t, -before, c.Area(), 1e-9)
+}
+
+// mark is a move that can not be transformed
+type mark struct {
+	At Vector
+}
+
+func (m mark) Move() (Vector, Vector) { return m.At, m.At }
+func (m mark) Reverse()               {}
+func (m mark) Equal(o Move) bool      { return o == Move(m) }
+
+func TestTransformOtherMoves(t *testing.T) {
+	p := Path{&Line{Vector{0, 0, 0}, Vector{1, 0, 0}}, mark{Vector{1, 0, 0}}}
+	p.Transform(Translation(Vector{1, 1, 0}))
+	assert.Equal(t, &Line{Vector{1, 1, 0}, Vector{2, 1, 0}}, p[0])
+	assert.Equal(t, mark{Vector{1, 0, 0}}, p[1])
+}
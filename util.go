@@ -4,6 +4,8 @@ import (
 	"log"
 	"math"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Log is used to print logs to stderr.
@@ -69,3 +71,13 @@ func bulgeToArc(p1 Vector, p2 Vector, bulge float64) (Vector, float64, float64,
 func vec2angle(v Vector) float64 {
 	return math.Atan2(v.Y, v.X)
 }
+
+// formatFloat formats a number with a fixed number of decimals, without
+// negative zeros.
+func formatFloat(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	if strings.Trim(s, "-0.") == "" {
+		s = strings.TrimPrefix(s, "-")
+	}
+	return s
+}
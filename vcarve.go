@@ -0,0 +1,314 @@
+package main
+
+// This file contains V-carving: a V bit follows the medial axis of a region,
+// going down until its sides touch the boundary on both sides, so the depth at
+// each point comes from the radius of the largest circle inscribed there.
+//
+// The medial axis is approximated from the Voronoi diagram of points sampled
+// along the boundary: the Delaunay triangulation of the samples is built, and
+// the centers of the circumscribed circles of neighbouring triangles are
+// joined, unless both triangles only see two neighbouring samples of the same
+// boundary.
+
+import (
+	"math"
+	"math/rand"
+)
+
+// VCarve describes the carving of a region with a V bit
+type VCarve struct {
+	Angle     float64 // included angle of the bit, in degrees
+	MaxDepth  float64 // flat depth limit, 0 for none
+	Spacing   float64 // distance between the samples of the boundary
+	ClearStep float64 // distance between the passes clearing the flat areas, 0 for none
+}
+
+// sample is a point of the boundary
+type sample struct {
+	At    Vector
+	Loop  int // index of the path it belongs to
+	Index int // position along the path
+}
+
+// triangle of the Delaunay triangulation, with its circumscribed circle
+type triangle struct {
+	v      [3]int
+	center Vector
+	r2     float64 // squared radius
+	bad    bool
+}
+
+func newTriangle(pts []Vector, a, b, c int) *triangle {
+	pa, pb, pc := pts[a], pts[b], pts[c]
+	d := 2 * (pa.X*(pb.Y-pc.Y) + pb.X*(pc.Y-pa.Y) + pc.X*(pa.Y-pb.Y))
+	t := &triangle{v: [3]int{a, b, c}}
+	if d == 0 {
+		// flat triangle, its circle is infinite
+		t.r2 = math.Inf(1)
+		return t
+	}
+	na, nb, nc := pa.Dot(pa), pb.Dot(pb), pc.Dot(pc)
+	t.center = Vector{
+		(na*(pb.Y-pc.Y) + nb*(pc.Y-pa.Y) + nc*(pa.Y-pb.Y)) / d,
+		(na*(pc.X-pb.X) + nb*(pa.X-pc.X) + nc*(pb.X-pa.X)) / d,
+		0,
+	}
+	t.r2 = t.center.Diff(pa).Dot(t.center.Diff(pa))
+	return t
+}
+
+// delaunay returns the Delaunay triangulation of the points, with the
+// Bowyer-Watson algorithm
+func delaunay(pts []Vector) []*triangle {
+	if len(pts) < 3 {
+		return nil
+	}
+	// super triangle, containing all the points
+	min, max := pts[0], pts[0]
+	for _, p := range pts {
+		min = Vector{math.Min(min.X, p.X), math.Min(min.Y, p.Y), 0}
+		max = Vector{math.Max(max.X, p.X), math.Max(max.Y, p.Y), 0}
+	}
+	size := math.Max(max.X-min.X, max.Y-min.Y) + 1
+	mid := min.Sum(max).Divide(2)
+	n := len(pts)
+	all := append(append([]Vector{}, pts...),
+		mid.Sum(Vector{-20 * size, -10 * size, 0}),
+		mid.Sum(Vector{20 * size, -10 * size, 0}),
+		mid.Sum(Vector{0, 20 * size, 0}),
+	)
+	tris := []*triangle{newTriangle(all, n, n+1, n+2)}
+
+	for i := 0; i < n; i++ {
+		p := all[i]
+		// edges of the cavity left by the triangles whose circle contains p
+		count := map[[2]int]int{}
+		edges := [][2]int{}
+		for _, t := range tris {
+			if t.center.Diff(p).Dot(t.center.Diff(p)) >= t.r2 {
+				continue
+			}
+			t.bad = true
+			for k := 0; k < 3; k++ {
+				e := [2]int{t.v[k], t.v[(k+1)%3]}
+				key := e
+				if key[0] > key[1] {
+					key[0], key[1] = key[1], key[0]
+				}
+				if count[key] == 0 {
+					edges = append(edges, e)
+				}
+				count[key]++
+			}
+		}
+		kept := tris[:0]
+		for _, t := range tris {
+			if !t.bad {
+				kept = append(kept, t)
+			}
+		}
+		tris = kept
+		for _, e := range edges {
+			key := e
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+			if count[key] == 1 {
+				tris = append(tris, newTriangle(all, e[0], e[1], i))
+			}
+		}
+	}
+
+	res := []*triangle{}
+	for _, t := range tris {
+		if t.v[0] < n && t.v[1] < n && t.v[2] < n {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// samples returns points along the boundary of the region, at most spacing
+// apart, including the ends of every move
+func (v VCarve) samples(region []Path) []sample {
+	ss := []sample{}
+	for i, p := range region {
+		k := 0
+		for _, m := range p {
+			mm := measure(m)
+			l := mm.Length()
+			n := int(math.Max(1, math.Ceil(l/v.Spacing)))
+			for j := 0; j < n; j++ {
+				ss = append(ss, sample{mm.At(l * float64(j) / float64(n)), i, k})
+				k++
+			}
+		}
+	}
+	return ss
+}
+
+// MedialAxis returns the segments of the medial axis of a region, as lines
+// whose ends have the radius of the inscribed circle as Z.
+func (v VCarve) MedialAxis(region []Path) []*Line {
+	region = orient(region)
+	ss := v.samples(region)
+	counts := make([]int, len(region))
+	for _, s := range ss {
+		counts[s.Loop]++
+	}
+	// slightly move the samples, so that the many cocircular points of lines
+	// and arcs do not make the triangulation degenerate
+	rnd := rand.New(rand.NewSource(1))
+	pts := make([]Vector, len(ss))
+	for i, s := range ss {
+		pts[i] = s.At.Sum(Vector{rnd.Float64() - 0.5, rnd.Float64() - 0.5, 0}.Multiply(v.Spacing * 1e-4))
+	}
+
+	// neighbours tells if two samples are next to each other on the boundary
+	neighbours := func(a, b int) bool {
+		sa, sb := ss[a], ss[b]
+		if sa.Loop != sb.Loop {
+			return false
+		}
+		d := sa.Index - sb.Index
+		if d < 0 {
+			d = -d
+		}
+		return d <= 1 || d == counts[sa.Loop]-1
+	}
+
+	tris := delaunay(pts)
+	inside := make([]bool, len(tris))
+	for i, t := range tris {
+		inside[i] = !math.IsInf(t.r2, 1) && winding(region, t.center) != 0
+	}
+	owners := map[[2]int][]int{}
+	for i, t := range tris {
+		for k := 0; k < 3; k++ {
+			a, b := t.v[k], t.v[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			owners[[2]int{a, b}] = append(owners[[2]int{a, b}], i)
+		}
+	}
+
+	lines := []*Line{}
+	// iterate over the triangles, so the result does not depend on the order
+	// of the map
+	for i, t := range tris {
+		for k := 0; k < 3; k++ {
+			a, b := t.v[k], t.v[(k+1)%3]
+			if a > b {
+				a, b = b, a
+			}
+			o := owners[[2]int{a, b}]
+			if len(o) != 2 || o[0] != i || neighbours(a, b) {
+				continue
+			}
+			t1, t2 := tris[o[0]], tris[o[1]]
+			if !inside[o[0]] || !inside[o[1]] || t1.center.near(t2.center) {
+				continue
+			}
+			from, to := t1.center, t2.center
+			from.Z, to.Z = math.Sqrt(t1.r2), math.Sqrt(t2.r2)
+			lines = append(lines, &Line{from, to})
+		}
+	}
+	return lines
+}
+
+// depth returns the depth reached by the bit touching a circle of radius r
+func (v VCarve) depth(r float64) float64 {
+	d := r / math.Tan(v.Angle*math.Pi/360)
+	if v.MaxDepth > 0 {
+		d = math.Min(d, v.MaxDepth)
+	}
+	return d
+}
+
+// Paths returns the paths followed by the tip of the bit, with the depth as
+// negative Z
+func (v VCarve) Paths(region []Path) []Path {
+	lines := v.MedialAxis(region)
+	for _, l := range lines {
+		l.From.Z = -v.depth(l.From.Z)
+		l.To.Z = -v.depth(l.To.Z)
+	}
+	return chainLines(lines)
+}
+
+// Clearing returns the paths clearing the flat bottom left where the bit is
+// limited by the maximum depth, at that depth
+func (v VCarve) Clearing(region []Path) []Path {
+	if v.MaxDepth <= 0 || v.ClearStep <= 0 {
+		return nil
+	}
+	r := v.MaxDepth * math.Tan(v.Angle*math.Pi/360)
+	paths := []Path{}
+	for inset := r; ; inset += v.ClearStep {
+		ps := Offset(region, -inset)
+		if len(ps) == 0 {
+			break
+		}
+		paths = append(paths, ps...)
+	}
+	return paths
+}
+
+// chainLines joins lines sharing their ends into paths. Paths start at the
+// ends of branches when possible.
+func chainLines(lines []*Line) []Path {
+	key := func(v Vector) [2]int64 {
+		return [2]int64{int64(math.Round(v.X / TOLERANCE)), int64(math.Round(v.Y / TOLERANCE))}
+	}
+	at := map[[2]int64][]int{}
+	for i, l := range lines {
+		at[key(l.From)] = append(at[key(l.From)], i)
+		at[key(l.To)] = append(at[key(l.To)], i)
+	}
+	used := make([]bool, len(lines))
+
+	follow := func(start int, from Vector) Path {
+		p := Path{}
+		i := start
+		for i >= 0 {
+			used[i] = true
+			l := *lines[i]
+			if key(l.From) != key(from) {
+				l.Reverse()
+			}
+			p = append(p, &l)
+			from = l.To
+			i = -1
+			next := at[key(from)]
+			if len(next) != 2 {
+				// end of a branch, or junction
+				break
+			}
+			for _, j := range next {
+				if !used[j] {
+					i = j
+				}
+			}
+		}
+		return p
+	}
+
+	paths := []Path{}
+	// branches first, starting from their ends and junctions
+	for i, l := range lines {
+		for _, end := range []Vector{l.From, l.To} {
+			if !used[i] && len(at[key(end)]) != 2 {
+				paths = append(paths, follow(i, end))
+			}
+		}
+	}
+	// then loops
+	for i, l := range lines {
+		if !used[i] {
+			paths = append(paths, follow(i, l.From))
+		}
+	}
+	return paths
+}
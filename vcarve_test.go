@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deepest returns the lowest height reached by the paths, and the point
+// where it is reached
+func deepest(paths []Path) Vector {
+	low := Vector{0, 0, math.Inf(1)}
+	for _, p := range paths {
+		for _, m := range p {
+			from, to := m.Move()
+			for _, v := range []Vector{from, to} {
+				if v.Z < low.Z {
+					low = v
+				}
+			}
+		}
+	}
+	return low
+}
+
+func TestMedialAxisSquare(t *testing.T) {
+	v := VCarve{Angle: 90, Spacing: 0.25}
+	lines := v.MedialAxis([]Path{square(0, 0, 10)})
+	assert.NotEmpty(t, lines)
+	for _, l := range lines {
+		for _, p := range []Vector{l.From, l.To} {
+			// the axis of a square is made of its diagonals
+			assert.True(t, math.Abs(p.X-p.Y) < 0.2 || math.Abs(p.X+p.Y-10) < 0.2, "%v off the diagonals", p)
+			// the radius is the distance to the nearest side
+			dist := math.Min(math.Min(p.X, 10-p.X), math.Min(p.Y, 10-p.Y))
+			assert.InDelta(t, dist, p.Z, 0.1)
+		}
+	}
+}
+
+func TestVCarveDepth(t *testing.T) {
+	region := []Path{polygon(Vector{0, 0, 0}, Vector{20, 0, 0}, Vector{20, 4, 0}, Vector{0, 4, 0})}
+
+	// 90° bit: the depth is the radius of the inscribed circle
+	v := VCarve{Angle: 90, Spacing: 0.2}
+	low := deepest(v.Paths(region))
+	assert.InDelta(t, -2, low.Z, 0.05)
+	assert.InDelta(t, 2, low.Y, 0.1)
+
+	// 60° bit goes deeper
+	v.Angle = 60
+	low = deepest(v.Paths(region))
+	assert.InDelta(t, -2*math.Sqrt(3), low.Z, 0.1)
+
+	// limited depth
+	v.MaxDepth = 1
+	low = deepest(v.Paths(region))
+	assert.InDelta(t, -1, low.Z, 1e-9)
+}
+
+func TestVCarveClearing(t *testing.T) {
+	region := []Path{square(0, 0, 10)}
+	v := VCarve{Angle: 90, Spacing: 0.25, MaxDepth: 1}
+	assert.Empty(t, v.Clearing(region), "no clearing without a step")
+
+	v.ClearStep = 1
+	paths := v.Clearing(region)
+	// the bit reaches the flat bottom 1 mm from the sides, then steps inward
+	// until the square is cleared
+	assert.Len(t, paths, 4)
+	b := paths[0].Bounds()
+	assert.InDelta(t, 1, b.Min.X, 1e-6)
+	assert.InDelta(t, 9, b.Max.X, 1e-6)
+}
+
+func TestVCarveOperation(t *testing.T) {
+	op := Operation{Kind: VCarving, Tool: 2, Depth: 1, Angle: 90, StepOver: 1}
+	passes, err := op.Passes(Model{square(0, 0, 10)})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, passes)
+
+	flat, carving := 0, 0
+	for _, p := range passes {
+		switch p.Depth {
+		case 1:
+			flat++
+		case 0:
+			carving++
+		}
+	}
+	assert.Equal(t, 4, flat)
+	assert.Equal(t, len(passes), flat+carving)
+	b := Toolpath{SafeZ: 5, Passes: passes}.Bounds()
+	assert.InDelta(t, -1, b.Min.Z, 1e-9)
+
+	tp := Toolpath{SafeZ: 5, Passes: passes}
+	doc := tp.Gcode()
+	assert.True(t, strings.Contains(doc.Export(3), "Z-0.5"), "the depth varies along the carving")
+
+	// the sampling does not depend on the tool
+	cuts := func(op Operation) int {
+		passes, err := op.Passes(Model{square(0, 0, 10)})
+		assert.NoError(t, err)
+		n := 0
+		for _, p := range passes {
+			if p.Depth == 0 {
+				n += len(p.Path)
+			}
+		}
+		return n
+	}
+	wide := op
+	wide.Tool = 6
+	assert.Equal(t, cuts(op), cuts(wide))
+	coarse := op
+	coarse.Tolerance = 0.5
+	assert.True(t, cuts(coarse) < cuts(op))
+}
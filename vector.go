@@ -3,8 +3,10 @@ package main
 import "math"
 import "fmt"
 
+// Vector is a point or a direction. Z is the height, relative to the depth of
+// the pass the move belongs to: 2D algorithms only use X and Y, and ignore it.
 type Vector struct {
-	X, Y float64
+	X, Y, Z float64
 }
 
 func (v Vector) Dot(o Vector) float64 {
@@ -19,6 +21,7 @@ func (v Vector) Sum(o Vector) Vector {
 	return Vector{
 		X: v.X + o.X,
 		Y: v.Y + o.Y,
+		Z: v.Z + o.Z,
 	}
 }
 
@@ -26,6 +29,7 @@ func (v Vector) Diff(o Vector) Vector {
 	return Vector{
 		X: v.X - o.X,
 		Y: v.Y - o.Y,
+		Z: v.Z - o.Z,
 	}
 }
 
@@ -33,6 +37,7 @@ func (v Vector) Divide(d float64) Vector {
 	return Vector{
 		X: v.X / d,
 		Y: v.Y / d,
+		Z: v.Z / d,
 	}
 }
 
@@ -40,6 +45,7 @@ func (v Vector) Multiply(d float64) Vector {
 	return Vector{
 		X: v.X * d,
 		Y: v.Y * d,
+		Z: v.Z * d,
 	}
 }
 